@@ -5,8 +5,11 @@ go 1.16
 require (
 	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/godbus/dbus/v5 v5.0.4
-	github.com/google/uuid v1.3.0
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/moniquelive/moniquelive-bot/shared v0.0.0-00010101000000-000000000000
 	github.com/onsi/gomega v1.12.0 // indirect
 	github.com/sirupsen/logrus v1.8.1
-	github.com/streadway/amqp v1.0.0
+	github.com/streadway/amqp v1.0.0 // indirect
 )
+
+replace github.com/moniquelive/moniquelive-bot/shared => ../shared
@@ -11,16 +11,15 @@ import (
 
 	"github.com/go-redis/redis"
 	"github.com/godbus/dbus/v5"
-	"github.com/google/uuid"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/shared/mq"
 	"github.com/sirupsen/logrus"
-	"github.com/streadway/amqp"
 )
 
 const (
-	musicUpdatedTopicName = "spotify_music_updated"
-	skipMusicTopicName    = "spotify_music_skip"
-	redisKey              = "twitch-bot:dbus:song-info"
-	queueName             = "ms.dbus"
+	producerName = "dbus"
+	redisKey     = "twitch-bot:dbus:song-info"
+	queueName    = "ms.dbus"
 )
 
 var (
@@ -30,14 +29,6 @@ var (
 	red      *redis.Client
 )
 
-type SongInfo struct {
-	ImgUrl  string `json:"imgUrl"`
-	SongUrl string `json:"songUrl"`
-	Title   string `json:"title"`
-	Artist  string `json:"artist"`
-	Length  int64  `json:"length"`
-}
-
 func init() {
 	logrus.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp:   true,
@@ -57,82 +48,38 @@ func check(err error) {
 }
 
 func main() {
-	var err error
+	client := mq.Dial(amqpURL)
 
-	conn, err := amqp.Dial(amqpURL)
+	dbusConn, err := dbus.ConnectSessionBus()
 	check(err)
-	defer conn.Close()
-	go func() { log.Debugf("closing: %s", <-conn.NotifyClose(make(chan *amqp.Error))) }()
-
-	dbusDoneChan := make(chan struct{})
-	mqDoneChan := make(chan struct{})
-
-	{
-		log.Debugln("getting sending Channel")
-		sendingMQChannel, err := conn.Channel()
-		check(err)
-		defer sendingMQChannel.Close()
-
-		go func() {
-			err := listenToDbus(sendingMQChannel, dbusDoneChan)
-			check(err)
-		}()
-	}
-
-	{
-		log.Debugln("getting receiving Channel")
-		channel, err := conn.Channel()
-		check(err)
-		defer channel.Close()
-
-		log.Debugf("declaring Queue %q", queueName)
-		queue, err := channel.QueueDeclare(
-			queueName, // name of the queue
-			true,      // durable
-			false,     // delete when unused
-			false,     // exclusive
-			false,     // noWait
-			nil,       // arguments
-		)
-		check(err)
-
-		log.Debugf("binding Queue %q to amq.topic", queueName)
-		err = channel.QueueBind(queueName, skipMusicTopicName, "amq.topic", false, nil)
-		check(err)
+	defer dbusConn.Close()
 
-		log.Debugln("Setting QoS")
-		err = channel.Qos(1, 0, true)
-		check(err)
+	err = client.Consume(mq.Topology{
+		Queue:      queueName,
+		Topics:     []string{events.TopicSongSkip},
+		MaxRetries: 2,
+	}, func(d *mq.Delivery) error {
+		return handle(d, dbusConn)
+	})
+	check(err)
 
-		log.Debugf("declared Queue (%q %d messages, %d consumers)", queue.Name, queue.Messages, queue.Consumers)
-
-		tag := uuid.NewString()
-		log.Debugf("starting Consume (tag:%q)", tag)
-		deliveries, err := channel.Consume(
-			queue.Name, // name
-			tag,        // consumerTag,
-			false,      // noAck
-			false,      // exclusive
-			false,      // noLocal
-			false,      // noWait
-			nil,        // arguments
-		)
+	dbusDoneChan := make(chan struct{})
+	go func() {
+		err := listenToDbus(client, dbusDoneChan)
 		check(err)
-
-		go handle(deliveries, mqDoneChan)
-	}
+	}()
 
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-signalChan
 
 	dbusDoneChan <- struct{}{}
-	mqDoneChan <- struct{}{}
+	_ = client.Close()
 
 	log.Debugln("AMQP consumer shutdown.")
 }
 
-func listenToDbus(channel *amqp.Channel, done <-chan struct{}) error {
+func listenToDbus(client *mq.Client, done <-chan struct{}) error {
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to connect to session bus:", err)
@@ -183,7 +130,7 @@ func listenToDbus(channel *amqp.Channel, done <-chan struct{}) error {
 			lengthInMillis := songData["mpris:length"].Value().(uint64) / 1000
 			artUrl = strings.ReplaceAll(artUrl, "open.spotify.com", "i.scdn.co")
 
-			songInfo := SongInfo{
+			songInfo := events.SongUpdated{
 				ImgUrl:  artUrl,
 				SongUrl: songUrl,
 				Title:   title,
@@ -196,51 +143,32 @@ func listenToDbus(channel *amqp.Channel, done <-chan struct{}) error {
 				log.Errorln("listenToDbus > jsonMarshal:", err)
 			}
 			red.Set(redisKey, infoBytes, time.Duration(lengthInMillis)*time.Millisecond)
-			err = channel.Publish("amq.topic", musicUpdatedTopicName, false, false, amqp.Publishing{
-				ContentType:     "application/json",
-				ContentEncoding: "utf-8",
-				DeliveryMode:    2,
-				Expiration:      "60000",
-				Body:            infoBytes,
-			})
+			body, err := events.Encode(producerName, songInfo)
 			if err != nil {
-				log.Errorln("listenToDbus > channel.Publish:", err)
+				log.Errorln("listenToDbus > events.Encode:", err)
+				continue
+			}
+			if err := client.Publish(songInfo.Topic(), body, time.Minute); err != nil {
+				log.Errorln("listenToDbus > client.Publish:", err)
 			}
 		}
 	}
 }
 
-func handle(deliveries <-chan amqp.Delivery, done <-chan struct{}) {
-	defer log.Println("AMQP Handler: Exiting from deliveries handler")
-
-	dbusConn, err := dbus.ConnectSessionBus()
+func handle(delivery *mq.Delivery, dbusConn *dbus.Conn) error {
+	env, err := events.Decode(delivery.Body)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to connect to session bus:", err)
-		return
+		return mq.Permanent(err)
 	}
-	defer dbusConn.Close()
+	log.Infof("%s from %s (%s)", env.Type, env.Producer, env.ID)
 
 	const spotify = "org.mpris.MediaPlayer2.spotify"
-
-	log.Debugln("MQ Listening...")
-	for {
-		select {
-		case <-done:
-			//log.Debugln("MQ CAINDO FUERAAAAA!!!")
-			return
-		case delivery := <-deliveries:
-			if delivery.Body != nil && len(delivery.Body) > 0 {
-				log.Infoln("SkipMusic Body (??):", string(delivery.Body))
-			}
-
-			switch delivery.RoutingKey {
-			case skipMusicTopicName:
-				dbusConn.
-					Object(spotify, "/org/mpris/MediaPlayer2").
-					Call("org.mpris.MediaPlayer2.Player.Next", 0)
-			}
-
-			_ = delivery.Ack(false)
-		}
+	switch env.Type {
+	case events.TopicSongSkip:
+		call := dbusConn.
+			Object(spotify, "/org/mpris/MediaPlayer2").
+			Call("org.mpris.MediaPlayer2.Player.Next", 0)
+		return call.Err
 	}
+	return nil
 }
@@ -3,12 +3,15 @@ module github.com/moniquelive/moniquelive-bot/perola
 go 1.16
 
 require (
-	github.com/google/uuid v1.2.0
+	github.com/google/uuid v1.3.0 // indirect
 	github.com/gorilla/websocket v1.4.2
+	github.com/moniquelive/moniquelive-bot/shared v0.0.0-00010101000000-000000000000
 	github.com/parnurzeal/gorequest v0.2.16
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sirupsen/logrus v1.8.1
-	github.com/streadway/amqp v1.0.0
+	github.com/streadway/amqp v1.0.0 // indirect
 	golang.org/x/net v0.0.0-20210510120150-4163338589ed // indirect
 	moul.io/http2curl v1.0.0 // indirect
 )
+
+replace github.com/moniquelive/moniquelive-bot/shared => ../shared
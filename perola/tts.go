@@ -2,10 +2,12 @@ package main
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"net/http"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -107,7 +109,7 @@ func dial() (*websocket.Conn, *http.Response, error) {
 	return websocket.DefaultDialer.Dial("wss://api.cybervox.ai/ws?access_token="+token, nil)
 }
 
-func tts(ws *websocket.Conn, responses <-chan ttsResponse, text, voice string) (response ttsResponse) {
+func tts(ws *websocket.Conn, responses <-chan ttsResponse, text, voice string) (response ttsResponse, err error) {
 	request := ttsRequest{
 		Emit: "tts",
 		Payload: ttsRequestPayload{
@@ -116,15 +118,123 @@ func tts(ws *websocket.Conn, responses <-chan ttsResponse, text, voice string) (
 			Timestamp: time.Now().UnixNano(),
 		},
 	}
-	_ = ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	if err := ws.WriteJSON(request); err != nil {
-		log.Println(err)
+	_ = ws.SetWriteDeadline(time.Now().Add(writeWait))
+	if err = ws.WriteJSON(request); err != nil {
 		return
 	}
-	response = <-responses
+	select {
+	case resp, ok := <-responses:
+		if !ok {
+			return response, errors.New("tts: connection closed")
+		}
+		return resp, nil
+	case <-time.After(responseWait):
+		return response, errors.New("tts: timeout waiting for response")
+	}
+}
+
+const (
+	writeWait    = 10 * time.Second    // Time allowed to write the data to the client.
+	pongWait     = 60 * time.Second    // Time allowed to read the next pong message from the client.
+	pingPeriod   = (pongWait * 9) / 10 // Send pings to client with this period. Must be less than pongWait.
+	responseWait = 30 * time.Second    // Time allowed for cybervox to answer a request.
+)
+
+// voxSession keeps the cybervox websocket open between deliveries and
+// redials it whenever a request finds it dead.
+type voxSession struct {
+	mu        sync.Mutex
+	ws        *websocket.Conn
+	responses chan ttsResponse
+	stop      chan struct{}
+}
+
+func (s *voxSession) tts(text, voice string) (resp ttsResponse, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a conexão pode ter morrido desde o último pedido: tenta de novo uma vez
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.ws == nil {
+			if err = s.connect(); err != nil {
+				return
+			}
+		}
+		if resp, err = tts(s.ws, s.responses, text, voice); err == nil {
+			return
+		}
+		log.Println("voxSession.tts:", err)
+		s.disconnect()
+	}
 	return
 }
 
+func (s *voxSession) connect() error {
+	ws, _, err := dial()
+	if err != nil {
+		return err
+	}
+	ws.SetReadLimit(512)
+	s.ws = ws
+	s.responses = make(chan ttsResponse, 1)
+	s.stop = make(chan struct{})
+
+	go func(ws *websocket.Conn, ch chan<- ttsResponse) {
+		defer close(ch)
+		_ = ws.SetReadDeadline(time.Now().Add(pongWait))
+		ws.SetPongHandler(func(string) error { return ws.SetReadDeadline(time.Now().Add(pongWait)) })
+		for {
+			var resp ttsResponse
+			if err := ws.ReadJSON(&resp); err != nil {
+				log.Debugln("NextReader:", err)
+				return
+			}
+			select {
+			case ch <- resp:
+			default:
+				// nobody is waiting (tts timed out), don't block the reader
+				log.Debugln("late response:", resp)
+			}
+		}
+	}(ws, s.responses)
+
+	go func(ws *websocket.Conn, stop <-chan struct{}) {
+		pingTicker := time.NewTicker(pingPeriod)
+		defer pingTicker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-pingTicker.C:
+				s.mu.Lock()
+				_ = ws.SetWriteDeadline(time.Now().Add(writeWait))
+				err := ws.WriteMessage(websocket.PingMessage, []byte{})
+				s.mu.Unlock()
+				if err != nil {
+					log.Debugln("Ping:", err)
+					return
+				}
+			}
+		}
+	}(ws, s.stop)
+	return nil
+}
+
+func (s *voxSession) disconnect() {
+	if s.ws == nil {
+		return
+	}
+	close(s.stop)
+	_ = s.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_ = s.ws.Close()
+	s.ws = nil
+}
+
+func (s *voxSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnect()
+}
+
 func ffplay(url string) {
 	const urlPrefix = "https://api.cybervox.ai"
 	cmd := fmt.Sprintf(`ffmpeg -i %q -filter:a "volume=5.0" -f wav - | ffplay -autoexit -nodisp -`,
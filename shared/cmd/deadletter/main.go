@@ -0,0 +1,58 @@
+// Command deadletter lists and re-publishes the messages a service gave up
+// on, e.g. a TTS redemption cybervox failed to synthesize.
+//
+//	RABBITMQ_URL=amqp://... deadletter list ms.tts
+//	RABBITMQ_URL=amqp://... deadletter replay ms.tts [n]
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/moniquelive/moniquelive-bot/shared/mq"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: deadletter list|replay <queue> [n]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+	n := 0
+	if len(os.Args) > 3 {
+		var err error
+		if n, err = strconv.Atoi(os.Args[3]); err != nil {
+			usage()
+		}
+	}
+	queue := os.Args[2]
+
+	client := mq.Dial(os.Getenv("RABBITMQ_URL"))
+	defer client.Close()
+
+	switch os.Args[1] {
+	case "list":
+		letters, err := client.InspectDeadLetters(queue, n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "list:", err)
+			os.Exit(1)
+		}
+		for i, l := range letters {
+			fmt.Printf("#%d %s (%s, %d retries)\n%s\n\n", i+1, l.Topic, l.Reason, l.Retries, l.Body)
+		}
+		fmt.Printf("%d dead letters in %s\n", len(letters), mq.DeadQueue(queue))
+	case "replay":
+		replayed, err := client.ReplayDeadLetters(queue, n)
+		fmt.Printf("%d dead letters replayed from %s\n", replayed, mq.DeadQueue(queue))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "replay:", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,244 @@
+// Package events holds the messages the bot services exchange over the
+// amq.topic exchange. Every message travels inside an Envelope, so consumers
+// can tell who produced it, when, and which schema version it follows.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion must be bumped whenever a payload changes in a way older
+// consumers can't read. Decode refuses envelopes from other versions.
+const SchemaVersion = 1
+
+// Routing keys used on amq.topic.
+const (
+	TopicSongUpdated    = "spotify_music_updated"
+	TopicSongSkip       = "spotify_music_skip"
+	TopicTTSRequested   = "create_tts"
+	TopicTTSCreated     = "tts_created"
+	TopicMarqueeUpdated = "marquee_updated"
+	TopicChatMessage    = "twitch_message_delivered"
+	TopicSongRequested  = "song_requested"
+	TopicCounterUpdated = "counter_updated"
+	TopicStreamStarted  = "stream_started"
+	TopicStreamStopped  = "stream_stopped"
+	TopicStreamViewers  = "stream_viewers"
+	TopicViewerGreeted  = "viewer_greeted"
+	TopicPollUpdated    = "poll_updated"
+	TopicRewardRedeemed = "reward_redeemed"
+	TopicOverlayEffect  = "overlay_effect"
+)
+
+// Where a stream session came from: helix polling or the !live command.
+const (
+	StreamSourceHelix   = "helix"
+	StreamSourceCommand = "command"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("events: unsupported schema version")
+	ErrTypeMismatch       = errors.New("events: type mismatch")
+)
+
+// Event is implemented by every payload type. Topic is both the routing key
+// and the envelope type.
+type Event interface {
+	Topic() string
+}
+
+type (
+	// SongUpdated is published by dbus whenever spotify starts a new track.
+	SongUpdated struct {
+		ImgUrl  string `json:"imgUrl"`
+		SongUrl string `json:"songUrl"`
+		Title   string `json:"title"`
+		Artist  string `json:"artist"`
+		Length  int64  `json:"length"`
+	}
+	// SongSkip asks dbus to jump to the next track.
+	SongSkip struct {
+		RequestedBy string `json:"requestedBy,omitempty"`
+	}
+	// TTSRequested asks perola to synthesize Text.
+	TTSRequested struct {
+		Text  string `json:"text"`
+		Voice string `json:"voice,omitempty"`
+		User  string `json:"user,omitempty"`
+	}
+	// TTSCreated carries the audio perola created for a TTSRequested.
+	TTSCreated struct {
+		AudioURL string `json:"audioUrl"`
+		Text     string `json:"text,omitempty"`
+	}
+	// MarqueeUpdated changes the text scrolling on the overlay.
+	MarqueeUpdated struct {
+		Text string `json:"text"`
+	}
+	// ChatMessage is a raw IRC line seen by the twitch service.
+	ChatMessage struct {
+		Raw string `json:"raw"`
+	}
+	// SongRequested is a channel-points song request waiting to be sent to
+	// spotify.
+	SongRequested struct {
+		URL  string `json:"url"`
+		User string `json:"user"`
+	}
+	// CounterUpdated is the new value of a chat counter (e.g. !ragejs).
+	CounterUpdated struct {
+		Name  string `json:"name"`
+		Value int64  `json:"value"`
+	}
+	// StreamStarted opens a stream session. Everything seen until the
+	// matching StreamStopped belongs to session ID.
+	StreamStarted struct {
+		ID        string    `json:"id"`
+		Title     string    `json:"title,omitempty"`
+		StartedAt time.Time `json:"startedAt"`
+		Source    string    `json:"source"` // StreamSourceHelix or StreamSourceCommand
+	}
+	// StreamStopped closes stream session ID.
+	StreamStopped struct {
+		ID string `json:"id"`
+	}
+	// StreamViewers is how many people were watching session ID last time
+	// helix was asked.
+	StreamViewers struct {
+		ID      string `json:"id"`
+		Viewers int    `json:"viewers"`
+	}
+	// ViewerGreeted shows a welcome card on the overlay. Kind is "first",
+	// "returning" or "raid".
+	ViewerGreeted struct {
+		Kind    string `json:"kind"`
+		User    string `json:"user"`
+		Viewers int    `json:"viewers,omitempty"` // raiders
+	}
+	// PollUpdated is how a chat poll (e.g. !skip) stands, after every vote
+	// and when it closes.
+	PollUpdated struct {
+		ID          string       `json:"id"`
+		Title       string       `json:"title"`
+		Options     []PollOption `json:"options"`
+		Quorum      int          `json:"quorum,omitempty"`
+		EndsAt      time.Time    `json:"endsAt"`
+		SecondsLeft int          `json:"secondsLeft"` // for the overlay countdown
+		Closed      bool         `json:"closed"`
+		Canceled    bool         `json:"canceled,omitempty"`
+		Winner      string       `json:"winner,omitempty"` // option that won, once closed
+	}
+	PollOption struct {
+		Name  string `json:"name"`
+		Votes int    `json:"votes"`
+	}
+	// RewardRedeemed is a channel points reward someone redeemed, for
+	// whoever wants to act on it.
+	RewardRedeemed struct {
+		Reward   string `json:"reward"` // name in commands.json
+		RewardID string `json:"rewardId"`
+		User     string `json:"user"`
+		Text     string `json:"text,omitempty"`
+	}
+	// OverlayEffect plays Effect on the overlay.
+	OverlayEffect struct {
+		Effect string `json:"effect"`
+		User   string `json:"user"`
+		Text   string `json:"text,omitempty"`
+	}
+)
+
+func (SongUpdated) Topic() string    { return TopicSongUpdated }
+func (SongSkip) Topic() string       { return TopicSongSkip }
+func (TTSRequested) Topic() string   { return TopicTTSRequested }
+func (TTSCreated) Topic() string     { return TopicTTSCreated }
+func (MarqueeUpdated) Topic() string { return TopicMarqueeUpdated }
+func (ChatMessage) Topic() string    { return TopicChatMessage }
+func (SongRequested) Topic() string  { return TopicSongRequested }
+func (CounterUpdated) Topic() string { return TopicCounterUpdated }
+func (StreamStarted) Topic() string  { return TopicStreamStarted }
+func (StreamStopped) Topic() string  { return TopicStreamStopped }
+func (StreamViewers) Topic() string  { return TopicStreamViewers }
+func (ViewerGreeted) Topic() string  { return TopicViewerGreeted }
+func (PollUpdated) Topic() string    { return TopicPollUpdated }
+func (RewardRedeemed) Topic() string { return TopicRewardRedeemed }
+func (OverlayEffect) Topic() string  { return TopicOverlayEffect }
+
+// Envelope is the wire format of every message.
+type Envelope struct {
+	Version       int             `json:"version"`
+	Type          string          `json:"type"`
+	ID            string          `json:"id"`
+	CorrelationID string          `json:"correlationId,omitempty"`
+	Producer      string          `json:"producer"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Option customizes an Envelope built by New or Encode.
+type Option func(*Envelope)
+
+// WithCorrelationID links the new envelope to a previous one (e.g. the
+// TTSCreated answering a TTSRequested).
+func WithCorrelationID(id string) Option {
+	return func(e *Envelope) { e.CorrelationID = id }
+}
+
+// New wraps ev in an Envelope stamped with producer, a fresh ID and the
+// current time.
+func New(producer string, ev Event, opts ...Option) (*Envelope, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("events: marshal %s: %w", ev.Topic(), err)
+	}
+	e := &Envelope{
+		Version:   SchemaVersion,
+		Type:      ev.Topic(),
+		ID:        uuid.NewString(),
+		Producer:  producer,
+		Timestamp: time.Now().UTC(),
+		Payload:   payload,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// Encode is New followed by json.Marshal.
+func Encode(producer string, ev Event, opts ...Option) ([]byte, error) {
+	e, err := New(producer, ev, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+// Decode parses an Envelope and checks its schema version.
+func Decode(body []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("events: decode envelope: %w", err)
+	}
+	if e.Version != SchemaVersion {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrUnsupportedVersion, e.Version, SchemaVersion)
+	}
+	return &e, nil
+}
+
+// Unmarshal decodes the payload into ev, which must be a pointer to the
+// payload type matching e.Type.
+func (e *Envelope) Unmarshal(ev Event) error {
+	if e.Type != ev.Topic() {
+		return fmt.Errorf("%w: envelope is %q, not %q", ErrTypeMismatch, e.Type, ev.Topic())
+	}
+	if err := json.Unmarshal(e.Payload, ev); err != nil {
+		return fmt.Errorf("events: decode %s: %w", e.Type, err)
+	}
+	return nil
+}
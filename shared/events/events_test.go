@@ -0,0 +1,66 @@
+package events_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	song := events.SongUpdated{
+		ImgUrl:  "https://i.scdn.co/image/abc",
+		SongUrl: "https://open.spotify.com/track/123",
+		Title:   "Ragatanga",
+		Artist:  "Rouge",
+		Length:  210,
+	}
+	body, err := events.Encode("dbus", song, events.WithCorrelationID("42"))
+	assert.NoError(t, err)
+
+	env, err := events.Decode(body)
+	assert.NoError(t, err)
+	assert.Equal(t, events.SchemaVersion, env.Version)
+	assert.Equal(t, events.TopicSongUpdated, env.Type)
+	assert.Equal(t, "dbus", env.Producer)
+	assert.Equal(t, "42", env.CorrelationID)
+	assert.NotEmpty(t, env.ID)
+	assert.False(t, env.Timestamp.IsZero())
+
+	var decoded events.SongUpdated
+	assert.NoError(t, env.Unmarshal(&decoded))
+	assert.Equal(t, song, decoded)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var tt = []struct {
+		name     string
+		body     string
+		expected error
+	}{
+		{"newer schema", `{"version":99,"type":"marquee_updated","payload":{}}`, events.ErrUnsupportedVersion},
+		{"missing version", `{"type":"marquee_updated","payload":{}}`, events.ErrUnsupportedVersion},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := events.Decode([]byte(tc.body))
+			assert.True(t, errors.Is(err, tc.expected), err)
+		})
+	}
+
+	_, err := events.Decode([]byte("plain text"))
+	var syntaxErr *json.SyntaxError
+	assert.True(t, errors.As(err, &syntaxErr), err)
+}
+
+func TestUnmarshalTypeMismatch(t *testing.T) {
+	body, err := events.Encode("twitch", events.MarqueeUpdated{Text: "oi"})
+	assert.NoError(t, err)
+	env, err := events.Decode(body)
+	assert.NoError(t, err)
+
+	var song events.SongUpdated
+	assert.True(t, errors.Is(env.Unmarshal(&song), events.ErrTypeMismatch))
+}
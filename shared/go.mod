@@ -0,0 +1,10 @@
+module github.com/moniquelive/moniquelive-bot/shared
+
+go 1.16
+
+require (
+	github.com/google/uuid v1.3.0
+	github.com/sirupsen/logrus v1.8.1
+	github.com/streadway/amqp v1.0.0
+	github.com/stretchr/testify v1.7.0
+)
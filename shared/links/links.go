@@ -0,0 +1,114 @@
+// Package links finds URLs in chat messages and tells whether they are
+// allowed, so the bot (which deletes them) and the stats (which keep them
+// for !urls) agree on it.
+package links
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// PolicyRedisKey holds the Policy of the bot, as JSON.
+	PolicyRedisKey = "twitch-bot:twitch:links:policy"
+	// PermitRedisKeyPrefix + login exists while login has a !permit.
+	PermitRedisKeyPrefix = "twitch-bot:twitch:links:permit:"
+)
+
+// Policy is who may post which links.
+type Policy struct {
+	Allow  []string `json:"allow"`  // see Allowed
+	Badges []string `json:"badges"` // badges that may post anything
+	Users  []string `json:"users"`  // logins that may post anything
+}
+
+// Exempt tells whether someone with login and badges may post anything.
+func (p Policy) Exempt(login string, badges map[string]int) bool {
+	for _, badge := range p.Badges {
+		if _, ok := badges[badge]; ok {
+			return true
+		}
+	}
+	for _, user := range p.Users {
+		if strings.EqualFold(user, login) {
+			return true
+		}
+	}
+	return false
+}
+
+var linkRegex = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b(?:[a-z0-9-]+\.)+(?:com|net|org|tv|gg|io|ly|me|br|ru|xyz|info|co|app|dev|link)\b(?:/\S*)?`)
+
+// Find returns the URLs (with or without http://) in text.
+func Find(text string) (found []string) {
+	for _, link := range linkRegex.FindAllString(text, -1) {
+		found = append(found, strings.TrimRight(link, `.,;:!?)"'`))
+	}
+	return
+}
+
+// Allowed tells whether link is in allow, a list of domains (which also allow
+// their subdomains) optionally followed by a path prefix where * matches any
+// one segment, e.g. "twitch.tv/*/clip".
+func Allowed(link string, allow []string) bool {
+	link = strings.ToLower(link)
+	for _, scheme := range []string{"https://", "http://"} {
+		link = strings.TrimPrefix(link, scheme)
+	}
+	host, path := link, ""
+	if i := strings.IndexAny(link, "/?#"); i >= 0 {
+		host, path = link[:i], link[i:]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.TrimPrefix(host, "www.")
+	for _, entry := range allow {
+		entry = strings.ToLower(entry)
+		domain, prefix := entry, ""
+		if i := strings.Index(entry, "/"); i >= 0 {
+			domain, prefix = entry[:i], entry[i:]
+		}
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllAllowed tells whether every link in text is allowed.
+func AllAllowed(text string, allow []string) bool {
+	for _, link := range Find(text) {
+		if !Allowed(link, allow) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	want := segments(prefix)
+	got := segments(path)
+	if len(got) < len(want) {
+		return false
+	}
+	for i, segment := range want {
+		if segment != "*" && segment != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func segments(path string) []string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	var s []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			s = append(s, segment)
+		}
+	}
+	return s
+}
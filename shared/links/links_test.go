@@ -0,0 +1,39 @@
+package links_test
+
+import (
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/shared/links"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	assert.Equal(t, []string{"https://github.com/moniquelive", "www.example.org", "bigfollows.com/x"},
+		links.Find("olha https://github.com/moniquelive e www.example.org ou bigfollows.com/x, ola.tudo bem?"))
+	assert.Empty(t, links.Find("e.g. nada aqui... 3.14"))
+}
+
+func TestAllowed(t *testing.T) {
+	allow := []string{"github.com", "open.spotify.com", "clips.twitch.tv", "twitch.tv/*/clip"}
+	var tt = []struct {
+		link     string
+		expected bool
+	}{
+		{"https://github.com/moniquelive/moniquelive-bot", true},
+		{"gist.github.com/x", true},
+		{"https://notgithub.com", false},
+		{"github.com.evil.ru/login", false},
+		{"HTTPS://OPEN.SPOTIFY.COM/track/123", true},
+		{"https://spotify.com", false},
+		{"https://clips.twitch.tv/AbcDef", true},
+		{"https://www.twitch.tv/moniquelive/clip/AbcDef?filter=clips", true},
+		{"twitch.tv/outracanal/clip/Xyz", true},
+		{"https://twitch.tv/moniquelive", false},
+		{"twitch.tv/clip", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.link, func(t *testing.T) {
+			assert.Equal(t, tc.expected, links.Allowed(tc.link, allow))
+		})
+	}
+}
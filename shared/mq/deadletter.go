@@ -0,0 +1,235 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// RetryCountHeader counts how many times a delivery was retried.
+	RetryCountHeader = "x-retry-count"
+	// OriginalTopicHeader keeps the routing key a retried delivery was
+	// first published with.
+	OriginalTopicHeader = "x-original-routing-key"
+	// DeathReasonHeader tells why a delivery went to the dead letter queue.
+	DeathReasonHeader = "x-dead-reason"
+
+	defaultRetryDelay = 5 * time.Second
+
+	// the dead letter queues are a place to look at, not an archive: the
+	// oldest letters go first
+	deadLetterTTL       = 7 * 24 * time.Hour
+	deadLetterMaxLength = 10000
+)
+
+// DeadQueue is the queue holding the dead letters of queue.
+func DeadQueue(queue string) string { return queue + ".dead" }
+
+// RetryQueue is the queue where failed deliveries of queue wait before
+// going back to it.
+func RetryQueue(queue string) string { return queue + ".retry" }
+
+type permanentError struct{ err error }
+
+func (p permanentError) Error() string { return p.err.Error() }
+func (p permanentError) Unwrap() error { return p.err }
+
+// Permanent marks err as not worth retrying (e.g. a malformed body): the
+// delivery goes straight to the dead letter queue.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Retries tells how many times this delivery has already been retried.
+func (d *Delivery) Retries() int {
+	switch n := d.Headers[RetryCountHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// Topic is the routing key the delivery was originally published with,
+// even after it went through the retry or the dead letter queue.
+func (d *Delivery) Topic() string {
+	if topic, ok := d.Headers[OriginalTopicHeader].(string); ok && topic != "" {
+		return topic
+	}
+	if death := firstDeath(d.Delivery); death != nil {
+		if keys, ok := death["routing-keys"].([]interface{}); ok && len(keys) > 0 {
+			if topic, ok := keys[0].(string); ok {
+				return topic
+			}
+		}
+	}
+	return d.RoutingKey
+}
+
+// declareDeadLetter declares the dead letter and retry queues of t. The
+// main queue has no dead-letter exchange: the client publishes the dead
+// letters itself, so messages that merely expired (chat lines, song events)
+// don't pile up in the dead letter queue while a consumer is down.
+func declareDeadLetter(channel *amqp.Channel, t Topology) error {
+	_, err := channel.QueueDeclare(DeadQueue(t.Queue), true, false, false, false, amqp.Table{
+		"x-message-ttl": deadLetterTTL.Milliseconds(),
+		"x-max-length":  int64(deadLetterMaxLength),
+	})
+	if err != nil {
+		return fmt.Errorf("queue declare %q: %w", DeadQueue(t.Queue), err)
+	}
+	// sem consumer: as mensagens expiram e voltam para a fila principal
+	_, err = channel.QueueDeclare(RetryQueue(t.Queue), true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": t.Queue,
+		"x-message-ttl":             t.RetryDelay.Milliseconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("queue declare %q: %w", RetryQueue(t.Queue), err)
+	}
+	return nil
+}
+
+func (c *Client) deadLetter(t Topology, d *Delivery, reason string) error {
+	msg := publishingFrom(d.Delivery)
+	msg.Headers[DeathReasonHeader] = reason
+	return c.PublishMessage("", DeadQueue(t.Queue), msg)
+}
+
+func (c *Client) retry(t Topology, d *Delivery) error {
+	msg := publishingFrom(d.Delivery)
+	msg.Headers[RetryCountHeader] = int32(d.Retries() + 1)
+	return c.PublishMessage("", RetryQueue(t.Queue), msg)
+}
+
+func publishingFrom(d amqp.Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		if k == "x-death" {
+			continue
+		}
+		headers[k] = v
+	}
+	if _, ok := headers[OriginalTopicHeader]; !ok {
+		headers[OriginalTopicHeader] = d.RoutingKey
+	}
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+// DeadLetter is a delivery sitting in a dead letter queue.
+type DeadLetter struct {
+	Topic   string
+	Retries int
+	Reason  string
+	Body    []byte
+}
+
+// InspectDeadLetters lists up to n dead letters of queue (all of them if
+// n <= 0) without removing them.
+func (c *Client) InspectDeadLetters(queue string, n int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := c.eachDeadLetter(queue, n, func(d amqp.Delivery) error {
+		dd := Delivery{Delivery: d}
+		letters = append(letters, DeadLetter{
+			Topic:   dd.Topic(),
+			Retries: dd.Retries(),
+			Reason:  deathReason(d),
+			Body:    d.Body,
+		})
+		return nil // left unacked: goes back to the queue with the channel
+	})
+	return letters, err
+}
+
+// ReplayDeadLetters re-publishes up to n dead letters of queue (all of them
+// if n <= 0) straight to queue, through the default exchange, with a fresh
+// retry count. Other queues bound to the same topic don't see them again.
+func (c *Client) ReplayDeadLetters(queue string, n int) (int, error) {
+	replayed := 0
+	err := c.eachDeadLetter(queue, n, func(d amqp.Delivery) error {
+		msg := publishingFrom(d) // keeps the original topic in a header
+		delete(msg.Headers, RetryCountHeader)
+		delete(msg.Headers, DeathReasonHeader)
+		if err := c.PublishMessage("", queue, msg); err != nil {
+			return err
+		}
+		replayed++
+		return d.Ack(false)
+	})
+	return replayed, err
+}
+
+// eachDeadLetter hands f every message Get returns from the dead letter
+// queue. Messages f doesn't ack return to the queue when the channel closes.
+func (c *Client) eachDeadLetter(queue string, n int, f func(d amqp.Delivery) error) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	for i := 0; n <= 0 || i < n; i++ {
+		delivery, ok, err := channel.Get(DeadQueue(queue), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if err := f(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deathReason(d amqp.Delivery) string {
+	if reason, ok := d.Headers[DeathReasonHeader].(string); ok {
+		return reason
+	}
+	if death := firstDeath(d); death != nil {
+		if reason, ok := death["reason"].(string); ok {
+			return reason
+		}
+	}
+	return ""
+}
+
+// firstDeath is the most recent entry RabbitMQ added to the x-death header.
+func firstDeath(d amqp.Delivery) amqp.Table {
+	deaths, ok := d.Headers["x-death"].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return nil
+	}
+	death, _ := deaths[0].(amqp.Table)
+	return death
+}
@@ -0,0 +1,52 @@
+package mq_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/shared/mq"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryHeaders(t *testing.T) {
+	var tt = []struct {
+		name            string
+		delivery        amqp.Delivery
+		expectedTopic   string
+		expectedRetries int
+	}{
+		{"fresh delivery",
+			amqp.Delivery{RoutingKey: "create_tts"},
+			"create_tts", 0},
+		{"back from the retry queue",
+			amqp.Delivery{RoutingKey: "ms.tts", Headers: amqp.Table{
+				mq.RetryCountHeader:    int32(2),
+				mq.OriginalTopicHeader: "create_tts",
+			}},
+			"create_tts", 2},
+		{"dead-lettered on first try",
+			amqp.Delivery{RoutingKey: "ms.tts", Headers: amqp.Table{
+				"x-death": []interface{}{amqp.Table{
+					"reason":       "rejected",
+					"routing-keys": []interface{}{"create_tts"},
+				}},
+			}},
+			"create_tts", 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &mq.Delivery{Delivery: tc.delivery}
+			assert.Equal(t, tc.expectedTopic, d.Topic())
+			assert.Equal(t, tc.expectedRetries, d.Retries())
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	cause := errors.New("json quebrado")
+	err := fmt.Errorf("handle: %w", mq.Permanent(cause))
+	assert.True(t, errors.Is(err, cause))
+	assert.Nil(t, mq.Permanent(nil))
+}
@@ -0,0 +1,446 @@
+// Package mq wraps streadway/amqp with what every bot service needs: topology
+// declared from a description, automatic reconnection with backoff,
+// consumers that come back after RabbitMQ restarts and a pooled publisher.
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+const (
+	DefaultExchange = "amq.topic"
+
+	minBackoff     = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+	publishTimeout = 5 * time.Second
+	publisherPool  = 4
+)
+
+var (
+	ErrClosed       = errors.New("mq: client closed")
+	ErrNotConnected = errors.New("mq: not connected")
+	ErrSettled      = errors.New("mq: delivery already settled")
+
+	log = logrus.WithField("package", "mq")
+)
+
+// Topology describes a service queue and the routing keys bound to it.
+type Topology struct {
+	Exchange   string        // defaults to amq.topic
+	Queue      string        // durable queue name, e.g. "ms.tts"
+	Topics     []string      // routing keys bound to Queue
+	Prefetch   int           // defaults to 1
+	MaxRetries int           // failed deliveries are retried this many times before dead-lettering
+	RetryDelay time.Duration // wait between retries, defaults to 5s
+}
+
+// Handler processes one delivery. If it returns without settling the
+// delivery, a nil error acks it and a non-nil error nacks it.
+type Handler func(d *Delivery) error
+
+// Delivery is an amqp.Delivery that remembers whether it was settled.
+type Delivery struct {
+	amqp.Delivery
+	settled    bool
+	deadLetter func(reason string) error // set by the consumer
+}
+
+// Ack confirms the delivery was processed.
+func (d *Delivery) Ack() error {
+	if d.settled {
+		return ErrSettled
+	}
+	d.settled = true
+	return d.Delivery.Ack(false)
+}
+
+// Nack rejects the delivery without putting it back in the queue: it goes
+// to the dead letter queue.
+func (d *Delivery) Nack() error {
+	return d.reject("rejected")
+}
+
+func (d *Delivery) reject(reason string) error {
+	if d.settled {
+		return ErrSettled
+	}
+	d.settled = true
+	if d.deadLetter == nil {
+		return d.Delivery.Nack(false, false)
+	}
+	if err := d.deadLetter(reason); err != nil {
+		log.Errorln("dead letter failed, requeueing:", err)
+		return d.Delivery.Nack(false, true)
+	}
+	return d.Delivery.Ack(false)
+}
+
+// Requeue puts the delivery back in the queue to be consumed again.
+func (d *Delivery) Requeue() error {
+	if d.settled {
+		return ErrSettled
+	}
+	d.settled = true
+	return d.Delivery.Nack(false, true)
+}
+
+type consumer struct {
+	topology Topology
+	handler  Handler
+}
+
+// Client owns a single AMQP connection, reconnecting whenever it drops.
+type Client struct {
+	url string
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	ready     chan struct{} // closed while conn is usable
+	consumers []*consumer
+	pool      chan *amqp.Channel
+
+	handlers sync.WaitGroup
+	closed   chan struct{}
+	done     chan struct{}
+}
+
+// Dial starts connecting to url in the background and returns right away.
+// Consumers registered before the connection is up start as soon as it is.
+func Dial(url string) *Client {
+	c := &Client{
+		url:    url,
+		ready:  make(chan struct{}),
+		pool:   make(chan *amqp.Channel, publisherPool),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+	backoff := minBackoff
+	wait := func() bool {
+		select {
+		case <-time.After(backoff):
+		case <-c.closed:
+			return false
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		return true
+	}
+	for {
+		conn, err := amqp.Dial(c.url)
+		if err != nil {
+			log.Errorf("dial failed, retrying in %v: %v", backoff, err)
+			if !wait() {
+				return
+			}
+			continue
+		}
+		notifyClose := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		c.mu.Lock()
+		c.conn = conn
+		consumers := append([]*consumer(nil), c.consumers...)
+		c.mu.Unlock()
+
+		log.Debugln("connected")
+		if err := c.startAll(conn, consumers); err != nil {
+			log.Errorf("%v, reconnecting in %v", err, backoff)
+			_ = conn.Close()
+			c.disconnected()
+			if !wait() {
+				return
+			}
+			continue
+		}
+		backoff = minBackoff
+		c.mu.Lock()
+		close(c.ready)
+		c.mu.Unlock()
+
+		select {
+		case err := <-notifyClose:
+			log.Errorln("connection closed:", err)
+			c.disconnected()
+		case <-c.closed:
+			// let the handlers ack what they're working on first
+			c.handlers.Wait()
+			c.disconnected()
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+func (c *Client) startAll(conn *amqp.Connection, consumers []*consumer) error {
+	for _, cons := range consumers {
+		if err := c.start(conn, cons); err != nil {
+			return fmt.Errorf("consumer %q: %w", cons.topology.Queue, err)
+		}
+	}
+	return nil
+}
+
+// disconnected forgets the connection. Whoever waits for it keeps waiting
+// for the next one.
+func (c *Client) disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = nil
+	select {
+	case <-c.ready:
+		c.ready = make(chan struct{})
+	default:
+	}
+	for {
+		select {
+		case ch := <-c.pool:
+			_ = ch.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Consume declares the topology and starts delivering messages to h. The
+// consumer is re-established on every reconnection.
+func (c *Client) Consume(t Topology, h Handler) error {
+	if t.Exchange == "" {
+		t.Exchange = DefaultExchange
+	}
+	if t.Prefetch == 0 {
+		t.Prefetch = 1
+	}
+	if t.RetryDelay == 0 {
+		t.RetryDelay = defaultRetryDelay
+	}
+	cons := &consumer{topology: t, handler: h}
+
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	c.consumers = append(c.consumers, cons)
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil // starts on connect
+	}
+	return c.start(conn, cons)
+}
+
+func (c *Client) start(conn *amqp.Connection, cons *consumer) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel: %w", err)
+	}
+	deliveries, tag, err := declare(channel, cons.topology)
+	if err != nil {
+		_ = channel.Close()
+		return err
+	}
+
+	c.handlers.Add(1)
+	go func() {
+		defer c.handlers.Done()
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-c.closed:
+				_ = channel.Cancel(tag, false)
+			case <-stop:
+			}
+		}()
+		log.Debugf("consuming %q (tag:%q)", cons.topology.Queue, tag)
+		for delivery := range deliveries {
+			d := &Delivery{Delivery: delivery}
+			d.deadLetter = func(reason string) error { return c.deadLetter(cons.topology, d, reason) }
+			c.dispatch(cons, d)
+		}
+		log.Debugf("deliveries closed for %q", cons.topology.Queue)
+		select {
+		case <-c.closed:
+		default:
+			// the channel died but the connection may still be up:
+			// drop it so run() reconnects and restarts every consumer
+			_ = conn.Close()
+		}
+	}()
+	return nil
+}
+
+func declare(channel *amqp.Channel, t Topology) (<-chan amqp.Delivery, string, error) {
+	if err := declareDeadLetter(channel, t); err != nil {
+		return nil, "", err
+	}
+	log.Debugf("declaring Queue %q", t.Queue)
+	queue, err := channel.QueueDeclare(
+		t.Queue, // name of the queue
+		true,    // durable
+		false,   // delete when unused
+		false,   // exclusive
+		false,   // noWait
+		nil,     // arguments
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("queue declare: %w", err)
+	}
+	for _, topic := range t.Topics {
+		log.Debugf("binding Queue %q to %s (%s)", t.Queue, t.Exchange, topic)
+		if err := channel.QueueBind(t.Queue, topic, t.Exchange, false, nil); err != nil {
+			return nil, "", fmt.Errorf("queue bind %q: %w", topic, err)
+		}
+	}
+	if err := channel.Qos(t.Prefetch, 0, false); err != nil {
+		return nil, "", fmt.Errorf("qos: %w", err)
+	}
+	log.Debugf("declared Queue (%q %d messages, %d consumers)", queue.Name, queue.Messages, queue.Consumers)
+
+	tag := uuid.NewString()
+	deliveries, err := channel.Consume(
+		queue.Name, // name
+		tag,        // consumerTag,
+		false,      // noAck
+		false,      // exclusive
+		false,      // noLocal
+		false,      // noWait
+		nil,        // arguments
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("consume: %w", err)
+	}
+	return deliveries, tag, nil
+}
+
+func (c *Client) dispatch(cons *consumer, d *Delivery) {
+	err := cons.handler(d)
+	if d.settled {
+		if err != nil {
+			log.Errorf("handler (%s): %v", d.Topic(), err)
+		}
+		return
+	}
+	if err == nil {
+		_ = d.Ack()
+		return
+	}
+	retries := d.Retries()
+	if isPermanent(err) || retries >= cons.topology.MaxRetries {
+		log.Errorf("handler (%s), dead-lettering after %d retries: %v", d.Topic(), retries, err)
+		_ = d.reject(err.Error())
+		return
+	}
+	log.Errorf("handler (%s), retry %d/%d in %v: %v",
+		d.Topic(), retries+1, cons.topology.MaxRetries, cons.topology.RetryDelay, err)
+	if err := c.retry(cons.topology, d); err != nil {
+		log.Errorln("retry failed, requeueing:", err)
+		_ = d.Requeue()
+		return
+	}
+	_ = d.Ack()
+}
+
+// Publish sends body to the default exchange with the given routing key.
+// A zero ttl means the message never expires.
+func (c *Client) Publish(topic string, body []byte, ttl time.Duration) error {
+	msg := amqp.Publishing{
+		ContentType:     "application/json",
+		ContentEncoding: "utf-8",
+		DeliveryMode:    amqp.Persistent,
+		Body:            body,
+	}
+	if ttl > 0 {
+		msg.Expiration = strconv.FormatInt(ttl.Milliseconds(), 10)
+	}
+	return c.PublishMessage(DefaultExchange, topic, msg)
+}
+
+// PublishMessage sends msg using one of the pooled channels.
+func (c *Client) PublishMessage(exchange, routingKey string, msg amqp.Publishing) error {
+	channel, err := c.channel()
+	if err != nil {
+		return err
+	}
+	if err := channel.Publish(exchange, routingKey, false, false, msg); err != nil {
+		_ = channel.Close()
+		return err
+	}
+	select {
+	case c.pool <- channel:
+	default:
+		_ = channel.Close()
+	}
+	return nil
+}
+
+func (c *Client) channel() (*amqp.Channel, error) {
+	select {
+	case channel := <-c.pool:
+		return channel, nil
+	default:
+	}
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Channel()
+}
+
+// connection waits a little for the connection to be up.
+func (c *Client) connection() (*amqp.Connection, error) {
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+	select {
+	case <-ready:
+	case <-c.closed:
+		return nil, ErrClosed
+	case <-time.After(publishTimeout):
+		return nil, ErrNotConnected
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+	return conn, nil
+}
+
+// Close stops the consumers, waits for running handlers and closes the
+// connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		return ErrClosed
+	default:
+		close(c.closed)
+	}
+	c.mu.Unlock()
+
+	c.handlers.Wait()
+	<-c.done
+	log.Debugln("AMQP client shutdown.")
+	return nil
+}
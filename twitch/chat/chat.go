@@ -0,0 +1,49 @@
+// Package chat describes what the command engine needs from a chat platform
+// (Twitch IRC, a local console, Discord...), so it doesn't depend on any of
+// them.
+package chat
+
+// User is whoever sent a message.
+type User struct {
+	ID          string
+	Name        string // login, lower case
+	DisplayName string
+	Color       string
+	Badges      map[string]int // e.g. "moderator", "subscriber", "broadcaster"
+}
+
+// Message is a chat message as seen by the bot.
+type Message struct {
+	ID     string // used to delete it, may be empty
+	User   User
+	Text   string
+	Emotes int               // how many emotes Text has
+	Reply  *Reply            // the message this one answers, if any
+	Tags   map[string]string // platform specific metadata (e.g. custom-reward-id)
+	Raw    string            // the message as received, for the stats
+}
+
+// Reply is the message someone answered to.
+type Reply struct {
+	User string // display name
+	Text string
+}
+
+// Sender is where the bot replies go.
+type Sender interface {
+	Say(msg string)
+	Whisper(user, msg string)
+}
+
+// Platform connects the bot to a chat.
+type Platform interface {
+	Sender
+	// OnMessage registers the callback for every chat message. Must be
+	// called before Connect.
+	OnMessage(func(Message))
+	// OnConnect registers a callback for (re)connections.
+	OnConnect(func())
+	// Connect blocks until the platform disconnects.
+	Connect() error
+	Disconnect() error
+}
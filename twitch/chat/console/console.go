@@ -0,0 +1,128 @@
+// Package console is a chat.Platform reading chat lines from stdin and
+// printing what the bot says to stdout, for trying commands locally.
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+type Platform struct {
+	in  io.Reader
+	out io.Writer
+
+	mu        sync.Mutex
+	user      chat.User
+	onMessage func(chat.Message)
+	onConnect func()
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ chat.Platform = (*Platform)(nil)
+
+// New returns a console where every line read from in is sent by user.
+func New(in io.Reader, out io.Writer, user chat.User) *Platform {
+	return &Platform{
+		in:        in,
+		out:       out,
+		user:      user,
+		onMessage: func(chat.Message) {},
+		onConnect: func() {},
+		done:      make(chan struct{}),
+	}
+}
+
+// SetUser changes who is typing from now on.
+func (p *Platform) SetUser(user chat.User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = user
+}
+
+func (p *Platform) User() chat.User {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.user
+}
+
+func (p *Platform) Say(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.out, "bot>", msg)
+}
+
+func (p *Platform) Whisper(user, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "bot> (sussurro para %s) %s\n", user, msg)
+}
+
+func (p *Platform) OnMessage(callback func(chat.Message)) {
+	p.onMessage = callback
+}
+
+func (p *Platform) OnConnect(callback func()) {
+	p.onConnect = callback
+}
+
+// NewUser returns a user with the comma separated badges, e.g.
+// "moderator,subscriber".
+func NewUser(name, badges string) chat.User {
+	user := chat.User{
+		ID:          "console:" + strings.ToLower(name),
+		Name:        strings.ToLower(name),
+		DisplayName: name,
+		Badges:      map[string]int{},
+	}
+	for _, badge := range strings.Split(badges, ",") {
+		if badge = strings.TrimSpace(badge); badge != "" {
+			user.Badges[badge] = 1
+		}
+	}
+	return user
+}
+
+// Connect reads lines until in is exhausted or Disconnect is called. A line
+// like "/as <user> [badges]" changes who is typing.
+func (p *Platform) Connect() error {
+	p.onConnect()
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(p.in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		errs <- scanner.Err()
+	}()
+	for {
+		select {
+		case line := <-lines:
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if fields := strings.Fields(line); fields[0] == "/as" && len(fields) > 1 {
+				user := NewUser(fields[1], strings.Join(fields[2:], ","))
+				p.SetUser(user)
+				fmt.Fprintf(p.out, "(agora você é %s %v)\n", user.DisplayName, user.Badges)
+				continue
+			}
+			p.onMessage(chat.Message{User: p.User(), Text: line, Raw: line})
+		case err := <-errs:
+			return err
+		case <-p.done:
+			return nil
+		}
+	}
+}
+
+func (p *Platform) Disconnect() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
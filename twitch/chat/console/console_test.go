@@ -0,0 +1,38 @@
+package console_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat/console"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsole(t *testing.T) {
+	var out bytes.Buffer
+	user := chat.User{Name: "alice", DisplayName: "Alice"}
+	p := console.New(strings.NewReader("!gh\n\n  !hug bob  \n/as Bob moderator vip\n!marquee\n"), &out, user)
+
+	var got []chat.Message
+	p.OnMessage(func(m chat.Message) {
+		got = append(got, m)
+		p.Say("/me " + m.Text)
+	})
+	assert.NoError(t, p.Connect())
+
+	bob := chat.User{
+		ID:          "console:bob",
+		Name:        "bob",
+		DisplayName: "Bob",
+		Badges:      map[string]int{"moderator": 1, "vip": 1},
+	}
+	assert.Equal(t, []chat.Message{
+		{User: user, Text: "!gh", Raw: "!gh"},
+		{User: user, Text: "!hug bob", Raw: "!hug bob"},
+		{User: bob, Text: "!marquee", Raw: "!marquee"},
+	}, got)
+	assert.Equal(t, "bot> /me !gh\nbot> /me !hug bob\n"+
+		"(agora você é Bob map[moderator:1 vip:1])\nbot> /me !marquee\n", out.String())
+}
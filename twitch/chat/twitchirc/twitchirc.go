@@ -0,0 +1,94 @@
+// Package twitchirc is the chat.Platform for a Twitch channel.
+package twitchirc
+
+import (
+	"errors"
+	"strings"
+
+	irc "github.com/gempir/go-twitch-irc/v2"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+type Platform struct {
+	// Client is exposed for the Twitch-only events (joins, parts, user
+	// state...).
+	Client  *irc.Client
+	channel string
+}
+
+var _ chat.Platform = (*Platform)(nil)
+
+func New(username, oauth, channel string) *Platform {
+	return &Platform{Client: irc.NewClient(username, oauth), channel: channel}
+}
+
+func (p *Platform) Say(msg string) {
+	p.Client.Say(p.channel, msg)
+}
+
+func (p *Platform) Whisper(user, msg string) {
+	p.Client.Whisper(user, msg)
+}
+
+func (p *Platform) OnMessage(callback func(chat.Message)) {
+	p.Client.OnPrivateMessage(func(message irc.PrivateMessage) {
+		text, reply := Reply(message)
+		emotes := 0
+		for _, emote := range message.Emotes {
+			emotes += emote.Count
+		}
+		callback(chat.Message{
+			ID:     message.ID,
+			User:   User(message.User),
+			Text:   text,
+			Emotes: emotes,
+			Reply:  reply,
+			Tags:   message.Tags,
+			Raw:    message.Raw,
+		})
+	})
+}
+
+// Reply finds the message this one answers. Twitch starts replies with
+// "@parent ", that is left out of the text so replies can be commands.
+func Reply(message irc.PrivateMessage) (text string, reply *chat.Reply) {
+	parent, ok := message.Tags["reply-parent-display-name"]
+	if !ok {
+		return message.Message, nil
+	}
+	reply = &chat.Reply{User: parent, Text: message.Tags["reply-parent-msg-body"]}
+	text = message.Message
+	if mention := "@" + strings.ToLower(parent) + " "; strings.HasPrefix(strings.ToLower(text), mention) {
+		text = text[len(mention):]
+	}
+	return text, reply
+}
+
+func (p *Platform) OnConnect(callback func()) {
+	p.Client.OnConnect(callback)
+}
+
+// Connect joins the channel and blocks until Disconnect is called.
+func (p *Platform) Connect() error {
+	p.Client.Join(p.channel)
+	err := p.Client.Connect()
+	if errors.Is(err, irc.ErrClientDisconnected) {
+		return nil
+	}
+	return err
+}
+
+func (p *Platform) Disconnect() error {
+	return p.Client.Disconnect()
+}
+
+// User converts an IRC user.
+func User(user irc.User) chat.User {
+	return chat.User{
+		ID:          user.ID,
+		Name:        user.Name,
+		DisplayName: user.DisplayName,
+		Color:       user.Color,
+		Badges:      user.Badges,
+	}
+}
@@ -0,0 +1,31 @@
+package twitchirc_test
+
+import (
+	"testing"
+
+	irc "github.com/gempir/go-twitch-irc/v2"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat/twitchirc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReply(t *testing.T) {
+	replyTags := map[string]string{"reply-parent-display-name": "Alice", "reply-parent-msg-body": "o bug era eu"}
+	var tt = []struct {
+		name          string
+		message       irc.PrivateMessage
+		expectedText  string
+		expectedReply *chat.Reply
+	}{
+		{"not a reply", irc.PrivateMessage{Message: "!quote"}, "!quote", nil},
+		{"reply", irc.PrivateMessage{Message: "@alice !quote add", Tags: replyTags}, "!quote add", &chat.Reply{User: "Alice", Text: "o bug era eu"}},
+		{"reply without the mention", irc.PrivateMessage{Message: "!quote add", Tags: replyTags}, "!quote add", &chat.Reply{User: "Alice", Text: "o bug era eu"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			text, reply := twitchirc.Reply(tc.message)
+			assert.Equal(t, tc.expectedText, text)
+			assert.Equal(t, tc.expectedReply, reply)
+		})
+	}
+}
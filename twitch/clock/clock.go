@@ -0,0 +1,17 @@
+// Package clock lets the schedulers be tested without waiting for real time.
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type real struct{}
+
+func (real) Now() time.Time                         { return time.Now() }
+func (real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Real is the wall clock.
+var Real Clock = real{}
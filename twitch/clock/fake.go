@@ -0,0 +1,40 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock for tests that only moves when told to. After never fires,
+// so the schedulers are driven by calling Tick by hand.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a fake clock stopped at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Fake) After(time.Duration) <-chan time.Time { return nil }
+
+// Advance moves the clock d forward.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Set moves the clock to now.
+func (c *Fake) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
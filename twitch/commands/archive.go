@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const searchLimit = 3
+
+var (
+	archiveURL    string
+	archiveClient = &http.Client{Timeout: 5 * time.Second}
+)
+
+// archivedMessage is what the twitch_stats archive answers, see
+// twitch_stats/archive.go
+type archivedMessage struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Text string    `json:"text"`
+}
+
+// SetArchive sets where twitch_stats serves the chat archive, e.g.
+// http://twitch-stats:9091
+func SetArchive(u string) {
+	archiveURL = u
+}
+
+// Search looks up the chat archive. Besides the text, cmdLine may have
+// @user, desde:2021-06-30 and ate:2021-07-01.
+func (c Commands) Search(cmdLine string) []string {
+	params := url.Values{"limit": {strconv.Itoa(searchLimit)}}
+	var phrase []string
+	for _, word := range strings.Fields(cmdLine) {
+		switch lower := strings.ToLower(word); {
+		case strings.HasPrefix(word, "@") && len(word) > 1:
+			params.Set("user", lower[1:])
+		case strings.HasPrefix(lower, "desde:"):
+			params.Set("from", word[len("desde:"):])
+		case strings.HasPrefix(lower, "ate:"):
+			params.Set("to", word[len("ate:"):])
+		case strings.HasPrefix(lower, "até:"):
+			params.Set("to", word[len("até:"):])
+		default:
+			phrase = append(phrase, word)
+		}
+	}
+	if len(params) == 1 && len(phrase) == 0 {
+		return []string{c.Ajuda("busca")}
+	}
+	params.Set("q", strings.Join(phrase, " "))
+
+	found, err := searchArchive(params)
+	if err != nil {
+		log.Errorln("Search > searchArchive:", err)
+		return []string{"Erro buscando no arquivo: " + err.Error()}
+	}
+	if len(found) == 0 {
+		return []string{"Nada encontrado... :("}
+	}
+	var lines []string
+	for _, m := range found {
+		lines = append(lines, fmt.Sprintf("[%v] %v: %v", m.Time.Local().Format("02/01 15:04"), m.User, m.Text))
+	}
+	return WordWrap(strings.Join(lines, " | "), 500)
+}
+
+func searchArchive(params url.Values) (found []archivedMessage, err error) {
+	if archiveURL == "" {
+		return nil, errors.New("arquivo do chat não configurado")
+	}
+	resp, err := archiveClient.Get(archiveURL + "/messages?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("arquivo respondeu %v", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&found)
+	return
+}
@@ -0,0 +1,60 @@
+package commands_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.URL.Query().Get("q") == "rust" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[
+			{"time": "2021-06-30T20:15:00Z", "user": "alice", "text": "bom dia"},
+			{"time": "2021-06-29T10:00:00Z", "user": "bob", "text": "bom dia, alice"}
+		]`))
+	}))
+	defer srv.Close()
+	commands.SetArchive(srv.URL)
+	defer commands.SetArchive("")
+
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!busca"], "responses": [""], "ajuda": "Busca no arquivo"}
+	]}`)))
+
+	var tt = []struct {
+		name          string
+		cmdLine       string
+		expectedQuery string
+		found         bool
+	}{
+		{"text", "bom dia", "limit=3&q=bom+dia", true},
+		{"user", "@Alice", "limit=3&q=&user=alice", true},
+		{"everything", "@bob dia desde:2021-06-01 até:2021-06-30", "from=2021-06-01&limit=3&q=dia&to=2021-06-30&user=bob", true},
+		{"nothing found", "rust", "limit=3&q=rust", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := c.Search(tc.cmdLine)
+			assert.Equal(t, tc.expectedQuery, query)
+			if tc.found {
+				assert.Len(t, lines, 1)
+				assert.Contains(t, lines[0], "alice: bom dia | [")
+				assert.Contains(t, lines[0], "bob: bom dia, alice")
+			} else {
+				assert.Equal(t, []string{"Nada encontrado... :("}, lines)
+			}
+		})
+	}
+	assert.Equal(t, []string{"!busca: Busca no arquivo (sinônimos: !busca)"}, c.Search(""))
+}
@@ -3,50 +3,95 @@ package commands
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
-	"net/url"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
 	"github.com/moniquelive/moniquelive-bot/twitch/spotify"
 
-	irc "github.com/gempir/go-twitch-irc/v2"
 	"github.com/go-redis/redis"
 	"github.com/nicklaw5/helix"
 	"github.com/sirupsen/logrus"
 )
 
 type Commands struct {
-	IgnoredCommands []string `json:"ignored-commands"`
-	Commands        []struct {
-		Actions   []string `json:"actions"`
-		Responses []string `json:"responses"`
-		Logs      []string `json:"logs"`
-		Extras    []string `json:"extras"`
-		Ajuda     string   `json:"ajuda"`
-		Help      string   `json:"help"`
-	} `json:"commands"`
-	ActionResponses map[string][]string
-	ActionLogs      map[string][]string
-	ActionExtras    map[string][]string
-	ActionAdmin     map[string]bool
-	ActionActions   map[string][]string
-	actionAjuda     map[string]string
-	actionHelp      map[string]string
+	IgnoredCommands  []string            `json:"ignored-commands"`
+	Language         string              `json:"language"`    // LanguagePtBr (default) or LanguageEn
+	AllowLists       map[string][]string `json:"allow-lists"` // name x logins, usable as a permission
+	Commands         []Command           `json:"commands"`
+	Timers           []Timer             `json:"timers"`
+	Greetings        Greetings           `json:"greetings"`
+	SongRequests     SongRequests        `json:"song-requests"`
+	SkipPoll         SkipPoll            `json:"skip-poll"`
+	Rewards          []Reward            `json:"rewards"`
+	Moderation       []ModerationRule    `json:"moderation"`
+	Lockdown         Lockdown            `json:"lockdown"`
+	ActionResponses  map[string][]string
+	ActionLogs       map[string][]string
+	ActionExtras     map[string][]string
+	ActionPermission map[string]string
+	ActionActions    map[string][]string
+	ActionCooldown   map[string]Cooldown
+	ActionCounter    map[string]string
+	actionAjuda      map[string]string
+	actionHelp       map[string]string
+	actionDenied     map[string]string
+	actionDeniedEn   map[string]string
+	custom           []Command // created from chat, see custom.go
+	check            TemplateChecker
+}
+
+// Command is an entry of commands.json.
+type Command struct {
+	Actions    []string `json:"actions"`
+	Responses  []string `json:"responses"`
+	Logs       []string `json:"logs"`
+	Extras     []string `json:"extras"`
+	Ajuda      string   `json:"ajuda"`
+	Help       string   `json:"help"`
+	Permission string   `json:"permission"`
+	Negado     string   `json:"negado"`
+	Denied     string   `json:"denied"`
+	Counter    string   `json:"counter"` // name of the counter incremented on every use
+	Cooldown
+}
+
+// Timer is a message said periodically in chat, e.g. reminding people to
+// drink water.
+type Timer struct {
+	Name        string   `json:"name"`
+	Interval    Duration `json:"interval"`
+	MinMessages int      `json:"min-messages"` // chat messages since the last time it was said
+	LiveOnly    bool     `json:"live-only"`
+	Responses   []string `json:"responses"`
+}
+
+// Cooldown limits how often a command can be used, by anyone (Global) or by
+// the same user (PerUser).
+type Cooldown struct {
+	Global  Duration `json:"cooldown"`
+	PerUser Duration `json:"user-cooldown"`
+	Reply   string   `json:"cooldown-reply"` // CooldownWhisper or CooldownSilent (default)
 }
 
+const (
+	CooldownWhisper = "whisper"
+	CooldownSilent  = "silent"
+)
+
 const (
 	redisUrlsKeyPrefix              = "twitch-bot:twitch_stats:urls:"
 	redisSeenAtKeyPrefix            = "twitch-bot:twitch_stats:seen_at:"
-	redisKeyCommandsPrefix          = "twitch-bot:twitch_stats:command:"
-	skipMusicTopicName              = "spotify_music_skip"
-	musicSkipPollName               = "twitch-bot:twitch:poll:skip_music"
-	musicKeepPollName               = "twitch-bot:twitch:poll:keep_music"
+	producerName                    = "twitch"
 	marqueeRedisKey                 = "twitch-bot:twitch:marquee:contents"
+	cooldownRedisKeyPrefix          = "twitch-bot:twitch:cooldown:"
 	appAccessTokenRedisKey          = "twitch-bot:twitch:app:access_token"
 	userAccessTokenRedisKey         = "twitch-bot:twitch:user:access_token"
 	userRefreshTokenRedisKey        = "twitch-bot:twitch:user:refresh_token"
@@ -57,14 +102,15 @@ const (
 
 var (
 	//https://en.wikipedia.org/wiki/Transformation_of_text#Upside-down_text
-	lower    = []rune{'\u007A', '\u028E', '\u0078', '\u028D', '\u028C', '\u006E', '\u0287', '\u0073', '\u0279', '\u0062', '\u0064', '\u006F', '\u0075', '\u026F', '\u006C', '\u029E', '\u017F', '\u1D09', '\u0265', '\u0253', '\u025F', '\u01DD', '\u0070', '\u0254', '\u0071', '\u0250'}
-	upper    = []rune{'\u005A', '\u2144', '\u0058', '\u004D', '\u039B', '\u0548', '\uA7B1', '\u0053', '\u1D1A', '\u10E2', '\u0500', '\u004F', '\u004E', '\uA7FD', '\u2142', '\uA4D8', '\u017F', '\u0049', '\u0048', '\u2141', '\u2132', '\u018E', '\u15E1', '\u0186', '\u15FA', '\u2200'}
-	digits   = []rune{'\u0036', '\u0038', '\u3125', '\u0039', '\u100C', '\u07C8', '\u218B', '\u218A', '\u21C2', '\u0030'}
-	punct    = []rune{'\u214B', '\u203E', '\u00BF', '\u00A1', '\u201E', '\u002C', '\u02D9', '\u0027', '\u061B'}
-	charMap  = map[rune]rune{}
-	log      = logrus.WithField("package", "commands")
-	redisURL = os.Getenv("REDIS_URL")
-	red      *redis.Client
+	lower   = []rune{'\u007A', '\u028E', '\u0078', '\u028D', '\u028C', '\u006E', '\u0287', '\u0073', '\u0279', '\u0062', '\u0064', '\u006F', '\u0075', '\u026F', '\u006C', '\u029E', '\u017F', '\u1D09', '\u0265', '\u0253', '\u025F', '\u01DD', '\u0070', '\u0254', '\u0071', '\u0250'}
+	upper   = []rune{'\u005A', '\u2144', '\u0058', '\u004D', '\u039B', '\u0548', '\uA7B1', '\u0053', '\u1D1A', '\u10E2', '\u0500', '\u004F', '\u004E', '\uA7FD', '\u2142', '\uA4D8', '\u017F', '\u0049', '\u0048', '\u2141', '\u2132', '\u018E', '\u15E1', '\u0186', '\u15FA', '\u2200'}
+	digits  = []rune{'\u0036', '\u0038', '\u3125', '\u0039', '\u100C', '\u07C8', '\u218B', '\u218A', '\u21C2', '\u0030'}
+	punct   = []rune{'\u214B', '\u203E', '\u00BF', '\u00A1', '\u201E', '\u002C', '\u02D9', '\u0027', '\u061B'}
+	charMap = map[rune]rune{}
+	log     = logrus.WithField("package", "commands")
+	red     *redis.Client
+
+	ErrInvalidSongURL = errors.New("url de música inválida")
 )
 var (
 	//go:embed .oauth_client_id
@@ -93,11 +139,12 @@ func init() {
 	charMap['}'] = '{'
 	charMap['['] = ']'
 	charMap[']'] = '['
+}
 
-	red = redis.NewClient(&redis.Options{Addr: redisURL})
-	if _, err := red.Ping().Result(); err != nil {
-		log.Fatalln("Commands.init > Sem redis...")
-	}
+// SetRedis sets the redis used by the commands. Must be called before any
+// command runs.
+func SetRedis(client *redis.Client) {
+	red = client
 }
 
 func fillMap(from, to rune, slice []rune) {
@@ -229,11 +276,11 @@ func (c Commands) Uptime(cmdLine string) string {
 		m.Truncate(time.Second))
 }
 
-func (c Commands) Marquee(user *irc.User, cmdLine string) string {
-	if !isAdmin(user) {
+func (c Commands) Marquee(user *chat.User, cmdLine string) string {
+	if !isModerator(user) {
 		return "Marquee > " + red.Get(marqueeRedisKey).Val()
 	}
-	if err := notifyAMQPTopic("marquee_updated", cmdLine); err != nil {
+	if err := notifyAMQPTopic(events.MarqueeUpdated{Text: cmdLine}); err != nil {
 		log.Errorln("Marquee > notifyAMQPTopic:", err)
 		return "Erro atualizando marquee: " + err.Error()
 	}
@@ -260,35 +307,7 @@ func (c Commands) Marquee(user *irc.User, cmdLine string) string {
 	return "Atualizando marquee: " + cmdLine
 }
 
-func (c Commands) SkipMusic(username string) string {
-	username = strings.ToLower(username)
-	red.SAdd(musicSkipPollName, username)
-	skipMembers := red.SMembers(musicSkipPollName).Val()
-	keepMembers := red.SMembers(musicKeepPollName).Val()
-	skipVotes := len(skipMembers) - 1
-	keepVotes := len(keepMembers) - 1
-	if skipVotes-keepVotes > 5 {
-		if err := notifyAMQPTopic(skipMusicTopicName, ""); err != nil {
-			log.Errorln("Skip > notifyAMQPTopic:", err)
-		}
-		sort.Strings(skipMembers)
-		return fmt.Sprintf("PULANDO!!!! 💃 (%v) X (%v)",
-			strings.Join(Remove(".", skipMembers), ", "),
-			strings.Join(Remove(".", keepMembers), ", "),
-		)
-	}
-	return fmt.Sprintf("Aaaaa parciais: (vaza: %v X fica: %v)", skipVotes, keepVotes)
-}
-
-func (c Commands) KeepMusic(username string) string {
-	username = strings.ToLower(username)
-	red.SAdd(musicKeepPollName, username)
-	keepVotes := len(red.SMembers(musicKeepPollName).Val()) - 1
-	skipVotes := len(red.SMembers(musicSkipPollName).Val()) - 1
-	return fmt.Sprintf("kumaPls parciais: (vaza: %v X fica: %v)", skipVotes, keepVotes)
-}
-
-func (c Commands) FollowAge(cmdLine string, sender *irc.User) string {
+func (c Commands) FollowAge(cmdLine string, sender *chat.User) string {
 	if len(cmdLine) > 1 && cmdLine[0] == '@' {
 		cmdLine = cmdLine[1:]
 	}
@@ -324,27 +343,75 @@ func (c Commands) FollowAge(cmdLine string, sender *irc.User) string {
 	return userName + " segue a Monique há " + FormatDuration(duration)
 }
 
-func (c *Commands) Reload() {
-	file, err := os.Open("./config/commands.json")
+// CooldownLeft tells how long user still has to wait before running action
+// again. When it returns zero the command may run and its cooldowns start
+// counting. Cooldowns are shared by all the aliases of a command and kept in
+// redis, so they survive restarts.
+func (c Commands) CooldownLeft(action string, user *chat.User) time.Duration {
+	cooldown := c.ActionCooldown[action]
+	if (cooldown.Global <= 0 && cooldown.PerUser <= 0) || isBroadcaster(user) {
+		return 0
+	}
+	key := cooldownRedisKeyPrefix + c.ActionActions[action][0][1:]
+	userKey := key + ":" + user.ID
+
+	// SETNX claims the cooldown atomically, so two messages arriving at once
+	// can't both run the command; the TTL is only read for the reply.
+	if cooldown.PerUser > 0 && !red.SetNX(userKey, user.Name, time.Duration(cooldown.PerUser)).Val() {
+		return ttlLeft(userKey)
+	}
+	if cooldown.Global > 0 && !red.SetNX(key, user.Name, time.Duration(cooldown.Global)).Val() {
+		if cooldown.PerUser > 0 {
+			red.Del(userKey)
+		}
+		return ttlLeft(key)
+	}
+	return 0
+}
+
+// ttlLeft is what's left of a cooldown key, at least a millisecond since it
+// may expire between the SETNX and the PTTL.
+func ttlLeft(key string) time.Duration {
+	if ttl := red.PTTL(key).Val(); ttl > 0 {
+		return ttl
+	}
+	return time.Millisecond
+}
+
+// Reload reads ConfigPath again. If it is broken the current commands are
+// kept and the problems are returned.
+func (c *Commands) Reload(check TemplateChecker) error {
+	next, err := Open(ConfigPath, check)
 	if err != nil {
-		log.Fatalln("erro ao abrir commands.json:", err)
+		return err
 	}
-	defer file.Close()
-	if err := json.NewDecoder(file).Decode(c); err != nil {
-		log.Fatalln("erro ao parsear commands.json:", err)
+	*c = next
+	return nil
+}
+
+// Load reads the commands from r (in the commands.json format).
+func (c *Commands) Load(r io.Reader) error {
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return err
 	}
 	c.refreshCache()
+	return nil
 }
 
 func (c *Commands) refreshCache() {
 	c.ActionLogs = make(map[string][]string)      // refresh action x logs map
 	c.ActionResponses = make(map[string][]string) // refresh action x responses map
 	c.ActionExtras = make(map[string][]string)    // refresh action x extras map
-	c.ActionAdmin = make(map[string]bool)         // refresh action x admin map
+	c.ActionPermission = make(map[string]string)  // refresh action x permission map
 	c.ActionActions = make(map[string][]string)   // refresh action x actions map
+	c.ActionCooldown = make(map[string]Cooldown)  // refresh action x cooldown map
+	c.ActionCounter = make(map[string]string)     // refresh action x counter name map
 	c.actionAjuda = make(map[string]string)       // refresh action x Ajuda texts
 	c.actionHelp = make(map[string]string)        // refresh action x Help texts
-	for _, command := range c.Commands {
+	c.actionDenied = make(map[string]string)      // refresh action x Negado texts
+	c.actionDeniedEn = make(map[string]string)    // refresh action x Denied texts
+	c.custom = c.customCommands()
+	for _, command := range c.all() {
 		responses := command.Responses
 		extras := command.Extras
 		actions := command.Actions
@@ -352,12 +419,20 @@ func (c *Commands) refreshCache() {
 		ajuda := command.Ajuda
 		help := command.Help
 		for _, action := range command.Actions {
+			if _, ok := c.ActionActions[action]; ok {
+				continue // the ones in commands.json come first and win
+			}
 			c.ActionResponses[action] = responses
 			c.ActionExtras[action] = extras
 			c.ActionActions[action] = actions
 			c.ActionLogs[action] = logs
 			c.actionAjuda[action] = ajuda
 			c.actionHelp[action] = help
+			c.ActionCooldown[action] = command.Cooldown
+			c.ActionPermission[action] = command.Permission
+			c.ActionCounter[action] = command.Counter
+			c.actionDenied[action] = command.Negado
+			c.actionDeniedEn[action] = command.Denied
 		}
 		if len(command.Actions) < 1 {
 			continue
@@ -365,16 +440,21 @@ func (c *Commands) refreshCache() {
 	}
 }
 
+// all returns the commands from commands.json followed by the custom ones.
+func (c *Commands) all() []Command {
+	return append(c.Commands[:len(c.Commands):len(c.Commands)], c.custom...)
+}
+
 func (c *Commands) Actions() string {
 	var sortedActions []string
-	for _, command := range c.Commands {
+	for _, command := range c.all() {
 		sortedActions = append(sortedActions, actionLabel(command.Actions))
 	}
 	sort.Strings(sortedActions)
 	return strings.Join(sortedActions, " ")
 }
 
-func (c Commands) Hug(sender *irc.User, cmdLine string) string {
+func (c Commands) Hug(sender *chat.User, cmdLine string) string {
 	if cmdLine == "" {
 		return c.Ajuda("hug")
 	}
@@ -388,42 +468,6 @@ func (c Commands) Hug(sender *irc.User, cmdLine string) string {
 	return fmt.Sprintf("♥ %s abraça %s 02Pat", sender.Name, cmdLine)
 }
 
-func (c Commands) SongRequest(user *irc.User, songUrl string) string {
-	client, err := authSpotify()
-	if err != nil {
-		return "Erro autenticando spotify: " + err.Error()
-	}
-
-	parsedUrl, err := url.Parse(songUrl)
-	if err != nil {
-		return "Música não encontrada:" + err.Error()
-	}
-	split := strings.Split(parsedUrl.Path, "/")
-	songId := split[len(split)-1]
-
-	songInfo, err := client.GetSongInfo(songId)
-	if err != nil {
-		return "Música não encontrada:" + err.Error()
-	}
-
-	if err = client.EnqueueSong(songId); err != nil {
-		return "Música não encontrada:" + err.Error()
-	}
-	return fmt.Sprintf("Enfileirando %q by %q (%v) - @%v",
-		songInfo.Name,
-		formattedArtists(songInfo),
-		FormatDuration(time.Duration(songInfo.DurationMs)*time.Millisecond),
-		user.DisplayName)
-}
-
-func formattedArtists(info *spotify.SongInfoResponse) string {
-	var artists []string
-	for _, artist := range info.Artists {
-		artists = append(artists, artist.Name)
-	}
-	return strings.Join(artists, ",")
-}
-
 func authHelix() (client *helix.Client, err error) {
 	client, err = helix.NewClient(&helix.Options{
 		ClientID:     oauth_client_id,
@@ -488,19 +532,8 @@ func authSpotify() (client *spotify.Client, err error) {
 	return
 }
 
-func isAdmin(user *irc.User) bool {
-	return user.ID == MoniqueliveID
-}
-
 func actionLabel(actions []string) string {
-	count := 0
-	for _, action := range actions {
-		key := redisKeyCommandsPrefix + action[1:]
-		str := red.Get(key).Val()
-		if i, err := strconv.Atoi(str); err == nil {
-			count += i
-		}
-	}
+	count := commandCount(actions, periodSession())
 	if count == 0 {
 		return actions[0]
 	}
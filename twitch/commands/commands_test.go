@@ -2,16 +2,85 @@ package commands_test
 
 import (
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
-	irc "github.com/gempir/go-twitch-irc/v2"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
 	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSongRequest(t *testing.T) {
 	c := commands.Commands{}
-	ret := c.SongRequest(&irc.User{
+	ret := c.SongRequest(&chat.User{
 		DisplayName: "cyberama",
 	}, "https://open.spotify.com/track/6OufwUcCqo81guU2jAlDVP?si=2a9566a0f7dc4f50")
 	log.Println(ret)
 }
+
+func TestCooldownLeft(t *testing.T) {
+	var c commands.Commands
+	err := c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!rainbow", "!r"], "responses": ["/me 🌈"], "cooldown": "1m"},
+		{"actions": ["!urls"], "responses": ["{{ .Command.Urls .CmdLine }}"], "user-cooldown": "1m", "cooldown-reply": "whisper"},
+		{"actions": ["!gh"], "responses": ["/me github"]}
+	]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, commands.CooldownWhisper, c.ActionCooldown["!urls"].Reply)
+
+	red.Del("twitch-bot:twitch:cooldown:rainbow", "twitch-bot:twitch:cooldown:urls:1", "twitch-bot:twitch:cooldown:urls:2")
+
+	alice := &chat.User{ID: "1", Name: "alice"}
+	bob := &chat.User{ID: "2", Name: "bob"}
+	var tt = []struct {
+		name     string
+		action   string
+		user     *chat.User
+		throttle bool
+	}{
+		{"first use", "!rainbow", alice, false},
+		{"global cooldown, other user", "!rainbow", bob, true},
+		{"global cooldown, alias", "!r", alice, true},
+		{"user cooldown, first use", "!urls", alice, false},
+		{"user cooldown, same user", "!urls", alice, true},
+		{"user cooldown, other user", "!urls", bob, false},
+		{"no cooldown", "!gh", alice, false},
+		{"no cooldown, again", "!gh", alice, false},
+		{"broadcaster skips cooldowns", "!rainbow", &chat.User{Badges: map[string]int{"broadcaster": 1}}, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			left := c.CooldownLeft(tc.action, tc.user)
+			assert.Equal(t, tc.throttle, left > 0)
+		})
+	}
+}
+
+func TestCooldownLeftConcurrent(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!hype"], "responses": ["/me 🎉"], "cooldown": "1m", "user-cooldown": "5m"}
+	]}`)))
+	red.Del("twitch-bot:twitch:cooldown:hype", "twitch-bot:twitch:cooldown:hype:1", "twitch-bot:twitch:cooldown:hype:2")
+
+	var ran int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.CooldownLeft("!hype", &chat.User{ID: "1", Name: "alice"}) == 0 {
+				atomic.AddInt32(&ran, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ran)
+
+	bob := &chat.User{ID: "2", Name: "bob"}
+	assert.True(t, c.CooldownLeft("!hype", bob) > 0)
+	assert.False(t, red.Exists("twitch-bot:twitch:cooldown:hype:2").Val() > 0,
+		"a throttled run doesn't start the user cooldown")
+}
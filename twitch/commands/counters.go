@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+
+	"github.com/go-redis/redis"
+)
+
+const counterRedisKeyPrefix = "twitch-bot:twitch:counter:"
+
+// Counter returns the value of the named counter (zero if it doesn't exist).
+func Counter(name string) (int64, error) {
+	value, err := red.Get(counterRedisKeyPrefix + name).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return value, err
+}
+
+// CounterAdd adds delta to the named counter and returns the new value.
+func CounterAdd(name string, delta int64) (int64, error) {
+	value, err := red.IncrBy(counterRedisKeyPrefix+name, delta).Result()
+	if err != nil {
+		return 0, err
+	}
+	notifyCounter(name, value)
+	return value, nil
+}
+
+// CounterSet changes the named counter to value.
+func CounterSet(name string, value int64) (int64, error) {
+	if err := red.Set(counterRedisKeyPrefix+name, value, 0).Err(); err != nil {
+		return 0, err
+	}
+	notifyCounter(name, value)
+	return value, nil
+}
+
+func notifyCounter(name string, value int64) {
+	if err := notifyAMQPTopic(events.CounterUpdated{Name: name, Value: value}); err != nil {
+		log.Errorln("notifyCounter > notifyAMQPTopic:", err)
+	}
+}
+
+// Count runs the counter of action. Moderators can "set <n>" or "reset" it,
+// otherwise it is incremented. A non-empty reply is said instead of the
+// command responses.
+func (c Commands) Count(action string, user *chat.User, cmdLine string) (value int64, reply string, err error) {
+	name := c.ActionCounter[action]
+	if name == "" {
+		return 0, "", fmt.Errorf("%s não tem contador", action)
+	}
+	args := strings.Fields(cmdLine)
+	if len(args) == 0 || !isModerator(user) {
+		value, err = CounterAdd(name, 1)
+		return value, "", err
+	}
+	switch {
+	case args[0] == "reset":
+		value, err = CounterSet(name, 0)
+	case args[0] == "set" && len(args) == 2:
+		var n int64
+		if n, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return 0, "uso: " + action + " set <número>", nil
+		}
+		value, err = CounterSet(name, n)
+	default:
+		value, err = CounterAdd(name, 1)
+		return value, "", err
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return value, fmt.Sprintf("contador %s = %d", name, value), nil
+}
@@ -0,0 +1,53 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCount(t *testing.T) {
+	red.Del("twitch-bot:twitch:counter:ragejs")
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!ragejs", "!rage"], "responses": ["/me {{ .Counter }}"], "counter": "ragejs"}
+	]}`)))
+	viewer := &chat.User{Name: "viewer"}
+	mod := &chat.User{Name: "mod", Badges: map[string]int{"moderator": 1}}
+
+	var tt = []struct {
+		name          string
+		action        string
+		user          *chat.User
+		cmdLine       string
+		expectedValue int64
+		expectedReply string
+	}{
+		{"first use", "!ragejs", viewer, "", 1, ""},
+		{"alias", "!rage", viewer, "", 2, ""},
+		{"viewers can't set", "!ragejs", viewer, "set 100", 3, ""},
+		{"mods can set", "!ragejs", mod, "set 100", 100, "contador ragejs = 100"},
+		{"bad number", "!ragejs", mod, "set cem", 0, "uso: !ragejs set <número>"},
+		{"after set", "!ragejs", mod, "", 101, ""},
+		{"reset", "!rage", mod, "reset", 0, "contador ragejs = 0"},
+		{"after reset", "!ragejs", viewer, "", 1, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			value, reply, err := c.Count(tc.action, tc.user, tc.cmdLine)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedValue, value)
+			assert.Equal(t, tc.expectedReply, reply)
+		})
+	}
+
+	value, err := commands.CounterAdd("ragejs", 9)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), value)
+	value, err = commands.Counter("nope")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), value)
+}
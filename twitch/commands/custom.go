@@ -0,0 +1,375 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+const (
+	customRedisKey      = "twitch-bot:twitch:custom-commands"       // hash action x CustomCommand
+	customAuditRedisKey = "twitch-bot:twitch:custom-commands:audit" // list of AuditEntry, newest first
+	customAuditSize     = 1000
+
+	ajudaFlag = " --ajuda "
+	helpFlag  = " --help "
+)
+
+// customChatCommands are the only chat commands a custom response may run:
+// the bot is a mod, so anything else (/ban, /timeout...) is off limits.
+var customChatCommands = []string{"/me", "/color"}
+
+// CustomCommand is a text command created from chat with !addcmd.
+type CustomCommand struct {
+	Action    string    `json:"action"`
+	Response  string    `json:"response"`
+	Ajuda     string    `json:"ajuda"`
+	Help      string    `json:"help"`
+	CreatedBy string    `json:"created-by"`
+	UpdatedBy string    `json:"updated-by"`
+	UpdatedAt time.Time `json:"updated-at"`
+}
+
+// AuditEntry records a change made to the custom commands.
+type AuditEntry struct {
+	At       time.Time `json:"at"`
+	User     string    `json:"user"`
+	Op       string    `json:"op"` // add, edit or del
+	Action   string    `json:"action"`
+	Response string    `json:"response,omitempty"`
+}
+
+// AddCommand handles "!addcmd !nome resposta [--ajuda texto] [--help text]".
+func (c *Commands) AddCommand(user *chat.User, cmdLine string) string {
+	custom, err := parseCustom(cmdLine)
+	if err != nil {
+		return err.Error()
+	}
+	if c.isFileCommand(custom.Action) {
+		return custom.Action + " é do commands.json, não dá para mudar pelo chat"
+	}
+	if _, ok := c.findCustom(custom.Action); ok {
+		return custom.Action + " já existe, use !editcmd"
+	}
+	if err := c.checkCustom(custom); err != nil {
+		return err.Error()
+	}
+	if custom.Ajuda == "" {
+		custom.Ajuda = "comando criado por " + user.DisplayName
+	}
+	if custom.Help == "" {
+		custom.Help = "command created by " + user.DisplayName
+	}
+	custom.CreatedBy = user.Name
+	return c.saveCustom("add", user, custom)
+}
+
+// EditCommand handles "!editcmd !nome nova resposta [--ajuda texto] [--help text]".
+// Help texts that are left out are kept.
+func (c *Commands) EditCommand(user *chat.User, cmdLine string) string {
+	custom, err := parseCustom(cmdLine)
+	if err != nil {
+		return err.Error()
+	}
+	old, ok := c.findCustom(custom.Action)
+	if !ok {
+		return custom.Action + " não existe, use !addcmd"
+	}
+	if custom.Ajuda == "" {
+		custom.Ajuda = old.Ajuda
+	}
+	if custom.Help == "" {
+		custom.Help = old.Help
+	}
+	if err := c.checkCustom(custom); err != nil {
+		return err.Error()
+	}
+	custom.CreatedBy = old.CreatedBy
+	return c.saveCustom("edit", user, custom)
+}
+
+// DeleteCommand handles "!delcmd !nome".
+func (c *Commands) DeleteCommand(user *chat.User, cmdLine string) string {
+	action := normalizeAction(cmdLine)
+	if action == "" {
+		return "uso: !delcmd !nome"
+	}
+	if _, ok := c.findCustom(action); !ok {
+		return action + " não existe"
+	}
+	if err := red.HDel(customRedisKey, action).Err(); err != nil {
+		return "Erro apagando " + action + ": " + err.Error()
+	}
+	audit(AuditEntry{User: user.Name, Op: "del", Action: action})
+	c.refreshCache()
+	return action + " apagado"
+}
+
+// CustomAudit returns the last n changes made to the custom commands.
+func CustomAudit(n int) ([]AuditEntry, error) {
+	values, err := red.LRange(customAuditRedisKey, 0, int64(n)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, len(values))
+	for _, value := range values {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (c *Commands) saveCustom(op string, user *chat.User, custom CustomCommand) string {
+	custom.UpdatedBy = user.Name
+	custom.UpdatedAt = time.Now()
+	bb, err := json.Marshal(custom)
+	if err != nil {
+		return "Erro salvando " + custom.Action + ": " + err.Error()
+	}
+	if err := red.HSet(customRedisKey, custom.Action, bb).Err(); err != nil {
+		return "Erro salvando " + custom.Action + ": " + err.Error()
+	}
+	audit(AuditEntry{User: user.Name, Op: op, Action: custom.Action, Response: custom.Response})
+	c.refreshCache()
+	if op == "add" {
+		return custom.Action + " criado"
+	}
+	return custom.Action + " atualizado"
+}
+
+func audit(entry AuditEntry) {
+	entry.At = time.Now()
+	bb, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorln("audit:", err)
+		return
+	}
+	red.LPush(customAuditRedisKey, bb)
+	red.LTrim(customAuditRedisKey, 0, customAuditSize-1)
+	log.Infof("custom command %s %s por %s", entry.Op, entry.Action, entry.User)
+}
+
+func (c *Commands) isFileCommand(action string) bool {
+	for _, command := range c.Commands {
+		if In(action, command.Actions) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Commands) findCustom(action string) (CustomCommand, bool) {
+	var custom CustomCommand
+	if red == nil {
+		return custom, false
+	}
+	bb, err := red.HGet(customRedisKey, action).Bytes()
+	if err != nil {
+		return custom, false
+	}
+	return custom, json.Unmarshal(bb, &custom) == nil
+}
+
+func (c *Commands) checkCustom(custom CustomCommand) error {
+	if err := checkCustomResponse(custom.Response); err != nil {
+		return err
+	}
+	if c.check == nil {
+		return nil
+	}
+	if err := c.check(custom.Response); err != nil {
+		return fmt.Errorf("template inválido: %v", err)
+	}
+	return nil
+}
+
+// checkCustomResponse rejects responses running chat commands or calling
+// anything from the template.
+func checkCustomResponse(text string) error {
+	for _, line := range strings.Split(text, "\n") {
+		if !SafeCustomLine(line) {
+			return fmt.Errorf("comandos do chat só podem começar com /me ou /color: %q", strings.TrimSpace(line))
+		}
+	}
+	return checkCustomTemplate(text)
+}
+
+// SafeCustomLine tells whether a line of a custom response, before or after
+// rendering, can be said in chat.
+func SafeCustomLine(line string) bool {
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || (line[0] != '/' && line[0] != '.') || !unicode.IsLetter(rune(line[1])) {
+		return true
+	}
+	command := strings.ToLower(strings.Fields(line)[0])
+	return In("/"+command[1:], customChatCommands)
+}
+
+// IsCustom tells whether action was created from chat.
+func (c *Commands) IsCustom(action string) bool {
+	if c.isFileCommand(action) {
+		return false
+	}
+	for _, command := range c.custom {
+		if In(action, command.Actions) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCustomTemplate keeps custom responses to plain data: they run for
+// every viewer, so no .Command methods nor functions that would lend them
+// the powers of whoever created the command.
+func checkCustomTemplate(text string) error {
+	tmpl, err := template.New("custom").Parse(text)
+	if err != nil {
+		return fmt.Errorf("template inválido: %v", err)
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return checkCustomNode(tmpl.Tree.Root)
+}
+
+func checkCustomNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkCustomNode(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.TextNode:
+		return nil
+	case *parse.ActionNode:
+		return checkCustomPipe(n.Pipe)
+	case *parse.IfNode:
+		return checkCustomBranch(n.BranchNode)
+	case *parse.WithNode:
+		return checkCustomBranch(n.BranchNode)
+	case *parse.RangeNode:
+		return checkCustomBranch(n.BranchNode)
+	}
+	return customTemplateError(node.String())
+}
+
+func checkCustomBranch(branch parse.BranchNode) error {
+	if err := checkCustomPipe(branch.Pipe); err != nil {
+		return err
+	}
+	if err := checkCustomNode(branch.List); err != nil {
+		return err
+	}
+	return checkCustomNode(branch.ElseList)
+}
+
+func checkCustomPipe(pipe *parse.PipeNode) error {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return customTemplateError("{{" + pipe.String() + "}}")
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode, *parse.StringNode, *parse.NumberNode, *parse.BoolNode:
+		return nil
+	case *parse.FieldNode:
+		if customField(arg.Ident) {
+			return nil
+		}
+	}
+	return customTemplateError("{{" + pipe.String() + "}}")
+}
+
+// customField tells whether a custom response may use the field .ident.
+func customField(ident []string) bool {
+	switch ident[0] {
+	case "Sender":
+		return true // chat.User is only data
+	case "CmdLine":
+		return len(ident) == 1
+	case "Roster":
+		return len(ident) == 1 || (len(ident) == 2 && ident[1] == "Keys")
+	}
+	return false
+}
+
+func customTemplateError(what string) error {
+	return fmt.Errorf("comandos do chat só podem usar .Sender, .CmdLine e .Roster: %v", what)
+}
+
+// customCommands reads the commands created from chat.
+func (c *Commands) customCommands() []Command {
+	if red == nil {
+		return nil
+	}
+	all, err := red.HGetAll(customRedisKey).Result()
+	if err != nil {
+		log.Errorln("customCommands:", err)
+		return nil
+	}
+	var commands []Command
+	for action, value := range all {
+		var custom CustomCommand
+		if err := json.Unmarshal([]byte(value), &custom); err != nil {
+			log.Errorf("customCommands: %s: %v", action, err)
+			continue
+		}
+		if err := checkCustomResponse(custom.Response); err != nil {
+			log.Errorf("customCommands: %s ignorado: %v", action, err)
+			continue
+		}
+		commands = append(commands, Command{
+			Actions:   []string{custom.Action},
+			Responses: []string{custom.Response},
+			Ajuda:     custom.Ajuda,
+			Help:      custom.Help,
+		})
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Actions[0] < commands[j].Actions[0] })
+	return commands
+}
+
+func parseCustom(cmdLine string) (CustomCommand, error) {
+	var custom CustomCommand
+	// flags can come in any order, so always cut the last one
+	for {
+		ajuda, help := strings.LastIndex(cmdLine, ajudaFlag), strings.LastIndex(cmdLine, helpFlag)
+		if ajuda < 0 && help < 0 {
+			break
+		}
+		if ajuda > help {
+			cmdLine, custom.Ajuda = cmdLine[:ajuda], strings.TrimSpace(cmdLine[ajuda+len(ajudaFlag):])
+		} else {
+			cmdLine, custom.Help = cmdLine[:help], strings.TrimSpace(cmdLine[help+len(helpFlag):])
+		}
+	}
+	split := strings.SplitN(strings.TrimSpace(cmdLine), " ", 2)
+	custom.Action = normalizeAction(split[0])
+	if custom.Action == "" || len(split) < 2 || strings.TrimSpace(split[1]) == "" {
+		return custom, fmt.Errorf("uso: !nome resposta [--ajuda texto] [--help text]")
+	}
+	custom.Response = strings.TrimSpace(split[1])
+	return custom, nil
+}
+
+func normalizeAction(action string) string {
+	action = strings.ToLower(strings.TrimSpace(action))
+	action = strings.TrimPrefix(action, "!")
+	if action == "" || strings.ContainsAny(action, " !") {
+		return ""
+	}
+	return "!" + action
+}
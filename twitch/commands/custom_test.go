@@ -0,0 +1,125 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomCommands(t *testing.T) {
+	red.Del("twitch-bot:twitch:custom-commands", "twitch-bot:twitch:custom-commands:audit")
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"help": "github", "ajuda": "github", "actions": ["!github", "!gh"], "responses": ["/me github"]}
+	]}`)))
+	mod := &chat.User{Name: "mod", DisplayName: "Mod"}
+
+	var tt = []struct {
+		name     string
+		run      func(*chat.User, string) string
+		cmdLine  string
+		expected string
+	}{
+		{"add", c.AddCommand, "!Discord /me entra no discord --ajuda Link do discord --help Discord link", "!discord criado"},
+		{"add again", c.AddCommand, "!discord /me outro", "!discord já existe, use !editcmd"},
+		{"add over commands.json", c.AddCommand, "!gh /me meu github", "!gh é do commands.json, não dá para mudar pelo chat"},
+		{"add without response", c.AddCommand, "!vazio", "uso: !nome resposta [--ajuda texto] [--help text]"},
+		{"edit", c.EditCommand, "discord /me entra no discord!", "!discord atualizado"},
+		{"edit missing", c.EditCommand, "!nope /me nope", "!nope não existe, use !addcmd"},
+		{"delete missing", c.DeleteCommand, "!nope", "!nope não existe"},
+		{"plain fields", c.AddCommand, "!oi /me {{ with .CmdLine }}oi {{ . }}{{ else }}oi {{ .Sender.DisplayName }}{{ end }}", "!oi criado"},
+		{"command methods", c.AddCommand, "!x {{ .Command.Permit .Sender.Name }}",
+			"comandos do chat só podem usar .Sender, .CmdLine e .Roster: {{.Command.Permit .Sender.Name}}"},
+		{"command fields", c.AddCommand, "!x {{ .Command.Commands }}",
+			"comandos do chat só podem usar .Sender, .CmdLine e .Roster: {{.Command.Commands}}"},
+		{"functions", c.AddCommand, "!x {{ len .Roster }}",
+			"comandos do chat só podem usar .Sender, .CmdLine e .Roster: {{len .Roster}}"},
+		{"pipes", c.AddCommand, "!x {{ .CmdLine | printf \"%q\" }}",
+			`comandos do chat só podem usar .Sender, .CmdLine e .Roster: {{.CmdLine | printf "%q"}}`},
+		{"variables", c.AddCommand, "!x {{ $c := .Command }}{{ $c.Unlock }}",
+			"comandos do chat só podem usar .Sender, .CmdLine e .Roster: {{$c := .Command}}"},
+		{"edit into methods", c.EditCommand, "!oi {{ .Command.AddCommand .Sender .CmdLine }}",
+			"comandos do chat só podem usar .Sender, .CmdLine e .Roster: {{.Command.AddCommand .Sender .CmdLine}}"},
+		{"delete", c.DeleteCommand, "!oi", "!oi apagado"},
+		{"chat commands", c.AddCommand, "!x /timeout {{ .CmdLine }}", `comandos do chat só podem começar com /me ou /color: "/timeout {{ .CmdLine }}"`},
+		{"dot chat commands", c.AddCommand, "!x .ban fulano", `comandos do chat só podem começar com /me ou /color: ".ban fulano"`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.run(mod, tc.cmdLine))
+		})
+	}
+
+	assert.Equal(t, []string{"/me entra no discord!"}, c.ActionResponses["!discord"])
+	assert.Equal(t, "!discord: Link do discord (sinônimos: !discord)", c.Ajuda("discord"))
+	assert.Equal(t, "!discord: Discord link (aliases: !discord)", c.Help("discord"))
+	assert.Equal(t, []string{"/me github"}, c.ActionResponses["!gh"])
+	assert.True(t, c.IsCustom("!discord"))
+	assert.False(t, c.IsCustom("!gh"))
+
+	// a fresh load (e.g. hot reload) sees the custom commands
+	var reloaded commands.Commands
+	assert.NoError(t, reloaded.Load(strings.NewReader(`{"commands": []}`)))
+	assert.Equal(t, []string{"/me entra no discord!"}, reloaded.ActionResponses["!discord"])
+
+	assert.Equal(t, "!discord apagado", c.DeleteCommand(mod, "!discord"))
+	_, ok := c.ActionResponses["!discord"]
+	assert.False(t, ok)
+
+	audit, err := commands.CustomAudit(10)
+	assert.NoError(t, err)
+	var ops []string
+	for _, entry := range audit {
+		assert.Equal(t, "mod", entry.User)
+		ops = append(ops, entry.Op+" "+entry.Action)
+	}
+	assert.Equal(t, []string{"del !discord", "del !oi", "add !oi", "edit !discord", "add !discord"}, ops)
+}
+
+func TestCustomCommandsOnStartup(t *testing.T) {
+	red.Del("twitch-bot:twitch:custom-commands")
+	defer red.Del("twitch-bot:twitch:custom-commands")
+	red.HSet("twitch-bot:twitch:custom-commands", "!discord",
+		`{"action": "!discord", "response": "/me entra no discord", "ajuda": "Link do discord", "created-by": "mod"}`)
+
+	path := filepath.Join(t.TempDir(), "commands.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"commands": [
+		{"help": "github", "ajuda": "github", "actions": ["!github"], "responses": ["/me github"]}
+	]}`), 0o644))
+	red.HSet("twitch-bot:twitch:custom-commands", "!mod",
+		`{"action": "!mod", "response": "{{ .Command.Permit .Sender.Name }}", "created-by": "mod"}`)
+	c, err := commands.Open(path, nil)
+	assert.NoError(t, err)
+	_, ok := c.ActionResponses["!mod"]
+	assert.False(t, ok, "stored templates calling methods are left out")
+	assert.Equal(t, []string{"/me entra no discord"}, c.ActionResponses["!discord"])
+	assert.Equal(t, []string{"/me github"}, c.ActionResponses["!github"])
+}
+
+func TestSafeCustomLine(t *testing.T) {
+	var tt = []struct {
+		line     string
+		expected bool
+	}{
+		{"oi", true},
+		{"/me oi", true},
+		{"/color red", true},
+		{"  /ME oi", true},
+		{"... oi", true},
+		{"/", true},
+		{"/ban fulano", false},
+		{" /timeout fulano 600", false},
+		{".ban fulano", false},
+		{"/mod fulano", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.line, func(t *testing.T) {
+			assert.Equal(t, tc.expected, commands.SafeCustomLine(tc.line))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+// Greetings are said when someone talks in the channel for the first time,
+// comes back after a while or raids us.
+type Greetings struct {
+	FirstMessage  []string `json:"first-message"`
+	Returning     []string `json:"returning"`
+	ReturningDays int      `json:"returning-days"` // days away to count as returning
+	Raid          []string `json:"raid"`
+	Overlay       bool     `json:"overlay"` // also shows a welcome card on the overlay
+}
+
+const (
+	GreetingFirst     = "first"
+	GreetingReturning = "returning"
+	GreetingRaid      = "raid"
+
+	lastMessageRedisKeyPrefix = "twitch-bot:twitch:greetings:last-message:"
+	lastMessageTTL            = 365 * 24 * time.Hour
+)
+
+// Greeting remembers user just talked and calls greet with how to welcome
+// them: GreetingFirst, GreetingReturning (and for how long they were away) or
+// "" when there's nothing to say. firstMessage comes from the first-msg tag;
+// otherwise the last message is looked up in redis and, for people redis
+// doesn't know, in the archive, on another goroutine so chat doesn't wait.
+func (c Commands) Greeting(user *chat.User, firstMessage bool, now time.Time, greet func(kind string, away time.Duration)) {
+	name := strings.ToLower(user.Name)
+	key := lastMessageRedisKeyPrefix + name
+	last, err := red.GetSet(key, now.Unix()).Int64()
+	red.Expire(key, lastMessageTTL)
+	if firstMessage {
+		greet(GreetingFirst, 0)
+		return
+	}
+	if err == redis.Nil {
+		go func() { greet(c.returning(lastArchived(name, now), now)) }()
+		return
+	}
+	greet(c.returning(last, now))
+}
+
+// returning tells whether someone that last talked at last (unix time, 0 for
+// never) is coming back.
+func (c Commands) returning(last int64, now time.Time) (kind string, away time.Duration) {
+	if last == 0 || c.Greetings.ReturningDays <= 0 {
+		return "", 0
+	}
+	away = now.Sub(time.Unix(last, 0))
+	if away < time.Duration(c.Greetings.ReturningDays)*24*time.Hour {
+		return "", 0
+	}
+	return GreetingReturning, away
+}
+
+// GreetingResponses are the templates of a kind of greeting.
+func (c Commands) GreetingResponses(kind string) []string {
+	switch kind {
+	case GreetingFirst:
+		return c.Greetings.FirstMessage
+	case GreetingReturning:
+		return c.Greetings.Returning
+	case GreetingRaid:
+		return c.Greetings.Raid
+	}
+	return nil
+}
+
+// lastArchived asks the archive when user last talked before now (leaving
+// out the message just sent, that may have been archived already).
+func lastArchived(user string, now time.Time) int64 {
+	if archiveURL == "" {
+		return 0
+	}
+	found, err := searchArchive(url.Values{
+		"user":  {user},
+		"to":    {now.Add(-time.Minute).Format(time.RFC3339)},
+		"limit": {strconv.Itoa(1)},
+	})
+	if err != nil {
+		log.Errorln("lastArchived > searchArchive:", err)
+		return 0
+	}
+	if len(found) == 0 {
+		return 0
+	}
+	return found[0].Time.Unix()
+}
@@ -0,0 +1,68 @@
+package commands_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGreeting(t *testing.T) {
+	now := time.Date(2021, 6, 30, 20, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("user") == "archived" {
+			_, _ = w.Write([]byte(`[{"time": "2021-05-31T20:00:00Z", "user": "archived", "text": "tchau"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	commands.SetArchive(srv.URL)
+	defer commands.SetArchive("")
+
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"greetings": {"returning-days": 14}}`)))
+	const prefix = "twitch-bot:twitch:greetings:last-message:"
+	red.Set(prefix+"yesterday", now.AddDate(0, 0, -1).Unix(), 0)
+	red.Set(prefix+"longago", now.AddDate(0, 0, -20).Unix(), 0)
+
+	var tt = []struct {
+		name         string
+		user         string
+		firstMessage bool
+		expected     string
+		expectedAway time.Duration
+	}{
+		{"first-msg tag", "newbie", true, commands.GreetingFirst, 0},
+		{"talking again", "newbie", false, "", 0},
+		{"seen yesterday", "yesterday", false, "", 0},
+		{"back after 20 days", "longago", false, commands.GreetingReturning, 20 * 24 * time.Hour},
+		{"greeted once", "longago", false, "", 0},
+		{"seen in the archive", "archived", false, commands.GreetingReturning, 30 * 24 * time.Hour},
+		{"never seen", "nobody", false, "", 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			type greeting struct {
+				kind string
+				away time.Duration
+			}
+			greeted := make(chan greeting, 1)
+			c.Greeting(&chat.User{Name: tc.user}, tc.firstMessage, now, func(kind string, away time.Duration) {
+				greeted <- greeting{kind, away}
+			})
+			select {
+			case g := <-greeted:
+				assert.Equal(t, tc.expected, g.kind)
+				assert.Equal(t, tc.expectedAway, g.away)
+			case <-time.After(time.Second):
+				t.Fatal("greet wasn't called")
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/lockdown"
+)
+
+// Lockdown is how waves of follow bots are spotted and what the bot does
+// about them.
+type Lockdown struct {
+	Window      Duration `json:"window"`       // how far back look-alike accounts count
+	MinAccounts int      `json:"min-accounts"` // look-alikes that make a wave, 0 turns it off
+	MinAffix    int      `json:"min-affix"`    // shared prefix or suffix that makes names alike
+	MaxDistance int      `json:"max-distance"` // edit distance that makes names alike
+	Cooldown    Duration `json:"cooldown"`     // quiet time before the chat is unlocked
+	Modes       []string `json:"modes"`        // Lockdown* modes switched on
+	Ban         bool     `json:"ban"`          // ban the accounts of the wave
+	Announce    string   `json:"announce"`
+}
+
+// Chat modes of a lockdown.
+const (
+	LockdownFollowers = "followers"
+	LockdownEmoteOnly = "emote-only"
+	LockdownSlow      = "slow"
+
+	defaultLockdownWindow   = time.Minute
+	defaultLockdownCooldown = 10 * time.Minute
+	defaultMinAffix         = 6
+	defaultLockdownAnnounce = "🚨 Onda de bots! Chat trancado por um tempo, já já a gente volta 💜"
+)
+
+// lockdownModes are the chat commands switching each mode on and off.
+var lockdownModes = map[string][2]string{
+	LockdownFollowers: {"/followers 30m", "/followersoff"},
+	LockdownEmoteOnly: {"/emoteonly", "/emoteonlyoff"},
+	LockdownSlow:      {"/slow 30", "/slowoff"},
+}
+
+var lockdownDetector *lockdown.Detector
+
+// SetLockdown sets the detector !unlock talks to.
+func SetLockdown(d *lockdown.Detector) {
+	lockdownDetector = d
+}
+
+// Config is the detector config, with the defaults filled in.
+func (l Lockdown) Config() lockdown.Config {
+	cfg := lockdown.Config{
+		Window:      time.Duration(l.Window),
+		MinAccounts: l.MinAccounts,
+		MinAffix:    l.MinAffix,
+		MaxDistance: l.MaxDistance,
+		Cooldown:    time.Duration(l.Cooldown),
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultLockdownWindow
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultLockdownCooldown
+	}
+	if cfg.MinAffix <= 0 {
+		cfg.MinAffix = defaultMinAffix
+	}
+	return cfg
+}
+
+// LockCommands are said when a lockdown starts.
+func (l Lockdown) LockCommands() (commands []string) {
+	for _, mode := range l.Modes {
+		commands = append(commands, lockdownModes[mode][0])
+	}
+	announce := l.Announce
+	if announce == "" {
+		announce = defaultLockdownAnnounce
+	}
+	return append(commands, "/me "+announce)
+}
+
+// UnlockCommands are said when it ends.
+func (l Lockdown) UnlockCommands() (commands []string) {
+	for _, mode := range l.Modes {
+		commands = append(commands, lockdownModes[mode][1])
+	}
+	return append(commands, "/me Lockdown encerrado, chat liberado 💚")
+}
+
+// Unlock handles !unlock. Unlocking says nothing here, see UnlockCommands.
+func (c Commands) Unlock() string {
+	if lockdownDetector == nil || !lockdownDetector.Unlock() {
+		return "Não tem lockdown nenhum 🤷"
+	}
+	return ""
+}
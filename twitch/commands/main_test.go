@@ -0,0 +1,24 @@
+package commands_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+)
+
+var red *redis.Client
+
+func TestMain(m *testing.M) {
+	fake, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	red = redis.NewClient(&redis.Options{Addr: fake.Addr()})
+	commands.SetRedis(red)
+	code := m.Run()
+	fake.Close()
+	os.Exit(code)
+}
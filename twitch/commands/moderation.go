@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/links"
+)
+
+// ModerationRule is an entry of the moderation section: what to look for in
+// chat messages (or follower names) and what to do about it.
+type ModerationRule struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind"`       // one of the Rule* kinds
+	Pattern   string   `json:"pattern"`    // regex, follow-name
+	Allow     []string `json:"allow"`      // links: what anyone may post, see links.Allowed
+	Ratio     float64  `json:"ratio"`      // caps: share of upper case letters
+	MinLength int      `json:"min-length"` // caps: letters for the rule to count
+	Max       int      `json:"max"`        // repeat: same character in a row; emotes: emotes in a message
+	Prefixes  []string `json:"prefixes"`   // follow-name
+	Suffixes  []string `json:"suffixes"`   // follow-name
+	Action    string   `json:"action"`     // one of the Moderation* actions
+	Duration  Duration `json:"duration"`   // timeout
+	Reason    string   `json:"reason"`
+	Exempt    string   `json:"exempt"` // permission that skips the rule, moderator by default
+}
+
+// Kinds of moderation rules.
+const (
+	RuleRegex      = "regex"
+	RuleLinks      = "links"
+	RuleCaps       = "caps"
+	RuleRepeat     = "repeat"
+	RuleEmotes     = "emotes"
+	RuleFollowName = "follow-name"
+)
+
+// Moderation actions.
+const (
+	ModerationTimeout = "timeout"
+	ModerationBan     = "ban"
+	ModerationDelete  = "delete"
+	ModerationWarn    = "warn"
+)
+
+const defaultPermit = time.Minute
+
+// badgeLevels are the badges behind each permission level, see Level.
+var badgeLevels = map[string]int{
+	"subscriber":  levels[PermissionSubscriber],
+	"founder":     levels[PermissionSubscriber],
+	"vip":         levels[PermissionVIP],
+	"moderator":   levels[PermissionModerator],
+	"broadcaster": levels[PermissionBroadcaster],
+}
+
+// Permit handles !permit <user> [segundos]: user may post any link for a
+// while (a minute by default).
+func (c Commands) Permit(cmdLine string) string {
+	fields := strings.Fields(cmdLine)
+	if len(fields) == 0 || len(fields) > 2 {
+		return c.Ajuda("permit")
+	}
+	user := strings.TrimPrefix(fields[0], "@")
+	duration := defaultPermit
+	if len(fields) == 2 {
+		seconds, err := strconv.Atoi(fields[1])
+		if err != nil || seconds <= 0 {
+			return c.Ajuda("permit")
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	if err := red.Set(links.PermitRedisKeyPrefix+strings.ToLower(user), 1, duration).Err(); err != nil {
+		log.Errorln("Permit > Set:", err)
+		return "Erro dando permissão: " + err.Error()
+	}
+	return fmt.Sprintf("@%v pode mandar links por %v 🔗", user, FormatDuration(duration))
+}
+
+// Permitted tells whether login got a !permit that is still going.
+func Permitted(login string) bool {
+	return red.Exists(links.PermitRedisKeyPrefix+strings.ToLower(login)).Val() == 1
+}
+
+// LinkPolicy is who may post which links, going by the first links rule.
+func (c Commands) LinkPolicy() (links.Policy, bool) {
+	for _, rule := range c.Moderation {
+		if rule.Kind != RuleLinks {
+			continue
+		}
+		exempt := rule.Exempt
+		if exempt == "" {
+			exempt = PermissionModerator
+		}
+		policy := links.Policy{Allow: rule.Allow}
+		required, ok := levels[exempt]
+		if !ok {
+			required = levels[PermissionBroadcaster]
+			policy.Users = c.AllowLists[exempt]
+		}
+		if required == levels[PermissionEveryone] {
+			return links.Policy{}, false
+		}
+		for badge, level := range badgeLevels {
+			if level >= required {
+				policy.Badges = append(policy.Badges, badge)
+			}
+		}
+		sort.Strings(policy.Badges)
+		return policy, true
+	}
+	return links.Policy{}, false
+}
+
+// SaveLinkPolicy leaves the LinkPolicy in redis, so the stats keep only the
+// links the bot doesn't delete.
+func (c Commands) SaveLinkPolicy() {
+	policy, ok := c.LinkPolicy()
+	if !ok {
+		red.Del(links.PolicyRedisKey)
+		return
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		log.Errorln("SaveLinkPolicy > Marshal:", err)
+		return
+	}
+	if err := red.Set(links.PolicyRedisKey, body, 0).Err(); err != nil {
+		log.Errorln("SaveLinkPolicy > Set:", err)
+	}
+}
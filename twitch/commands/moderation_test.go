@@ -0,0 +1,82 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/links"
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/lockdown"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermit(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!permit"], "ajuda": "libera links", "responses": ["{{ .Command.Permit .CmdLine }}"]}
+	]}`)))
+
+	assert.Equal(t, "!permit: libera links (sinônimos: !permit)", c.Permit(""))
+	assert.Equal(t, "!permit: libera links (sinônimos: !permit)", c.Permit("fulano já"))
+	assert.False(t, commands.Permitted("fulano"))
+	assert.Equal(t, "@Fulano pode mandar links por 1 minuto 🔗", c.Permit("@Fulano"))
+	assert.True(t, commands.Permitted("fulano"))
+	assert.Equal(t, "@beltrano pode mandar links por 2 minutos 🔗", c.Permit("beltrano 120"))
+	assert.True(t, commands.Permitted("Beltrano"))
+}
+
+func TestLinkPolicy(t *testing.T) {
+	var tt = []struct {
+		name     string
+		json     string
+		expected *links.Policy
+	}{
+		{"no links rule", `{"moderation": [{"name": "flood", "kind": "repeat", "max": 10, "action": "delete"}]}`, nil},
+		{"mods by default", `{"moderation": [{"name": "links", "kind": "links", "allow": ["github.com"], "action": "delete"}]}`,
+			&links.Policy{Allow: []string{"github.com"}, Badges: []string{"broadcaster", "moderator"}}},
+		{"vips", `{"moderation": [{"name": "links", "kind": "links", "action": "delete", "exempt": "vip"}]}`,
+			&links.Policy{Badges: []string{"broadcaster", "moderator", "vip"}}},
+		{"allow-list", `{"allow-lists": {"amigos": ["fulano"]},
+			"moderation": [{"name": "links", "kind": "links", "action": "delete", "exempt": "amigos"}]}`,
+			&links.Policy{Badges: []string{"broadcaster"}, Users: []string{"fulano"}}},
+		{"everyone", `{"moderation": [{"name": "links", "kind": "links", "action": "delete", "exempt": "everyone"}]}`, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c commands.Commands
+			assert.NoError(t, c.Load(strings.NewReader(tc.json)))
+			policy, ok := c.LinkPolicy()
+			assert.Equal(t, tc.expected != nil, ok)
+			if tc.expected != nil {
+				assert.Equal(t, *tc.expected, policy)
+			}
+		})
+	}
+}
+
+func TestLockdown(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"lockdown": {"min-accounts": 5, "modes": ["followers", "slow"]}}`)))
+
+	assert.Equal(t, lockdown.Config{Window: time.Minute, MinAccounts: 5, MinAffix: 6, Cooldown: 10 * time.Minute},
+		c.Lockdown.Config())
+	assert.Equal(t, []string{"/followers 30m", "/slow 30",
+		"/me 🚨 Onda de bots! Chat trancado por um tempo, já já a gente volta 💜"}, c.Lockdown.LockCommands())
+	assert.Equal(t, []string{"/followersoff", "/slowoff", "/me Lockdown encerrado, chat liberado 💚"},
+		c.Lockdown.UnlockCommands())
+
+	unlocked := 0
+	detector := lockdown.New(clock.NewFake(time.Unix(0, 0)), func(lockdown.Wave) {}, func() { unlocked++ })
+	detector.Configure(lockdown.Config{Window: time.Minute, MinAccounts: 2, MinAffix: 6})
+	commands.SetLockdown(detector)
+	defer commands.SetLockdown(nil)
+
+	assert.Equal(t, "Não tem lockdown nenhum 🤷", c.Unlock())
+	detector.Arrived("hoss00312_aa1")
+	assert.True(t, detector.Arrived("hoss00312_bb2"))
+	assert.Equal(t, "", c.Unlock())
+	assert.Equal(t, 1, unlocked)
+	assert.False(t, detector.Locked())
+}
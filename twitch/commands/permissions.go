@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+// Permission levels for the `permission` field of a command. Any other value
+// names one of the `allow-lists`.
+const (
+	PermissionEveryone    = "everyone"
+	PermissionSubscriber  = "subscriber"
+	PermissionVIP         = "vip"
+	PermissionModerator   = "moderator"
+	PermissionBroadcaster = "broadcaster"
+)
+
+const (
+	LanguagePtBr = "pt-br"
+	LanguageEn   = "en"
+)
+
+var levels = map[string]int{
+	PermissionEveryone:    0,
+	PermissionSubscriber:  1,
+	PermissionVIP:         2,
+	PermissionModerator:   3,
+	PermissionBroadcaster: 4,
+}
+
+var defaultDenied = map[string]string{
+	LanguagePtBr: "Desculpa ai {{ .Sender.DisplayName }}, esse não é pra você!",
+	LanguageEn:   "Sorry {{ .Sender.DisplayName }}, you can't use that one!",
+}
+
+// Level returns the highest permission level granted by the user badges.
+func Level(user *chat.User) int {
+	has := func(badge string) bool {
+		_, ok := user.Badges[badge]
+		return ok
+	}
+	switch {
+	case has("broadcaster"):
+		return levels[PermissionBroadcaster]
+	case has("moderator"):
+		return levels[PermissionModerator]
+	case has("vip"):
+		return levels[PermissionVIP]
+	case has("subscriber"), has("founder"):
+		return levels[PermissionSubscriber]
+	}
+	return levels[PermissionEveryone]
+}
+
+// HasPermission tells whether user is allowed by permission, which is either
+// a level or the name of an allow-list. The broadcaster is always allowed.
+func (c Commands) HasPermission(permission string, user *chat.User) bool {
+	if permission == "" {
+		permission = PermissionEveryone
+	}
+	level := Level(user)
+	if required, ok := levels[permission]; ok {
+		return level >= required
+	}
+	if level == levels[PermissionBroadcaster] {
+		return true
+	}
+	return In(strings.ToLower(user.Name), c.AllowLists[permission])
+}
+
+// Allowed tells whether user may run action.
+func (c Commands) Allowed(action string, user *chat.User) bool {
+	return c.HasPermission(c.ActionPermission[action], user)
+}
+
+// Denied returns the (template) message said when someone without permission
+// tries to run action, in the configured language.
+func (c Commands) Denied(action string) string {
+	denied := c.actionDenied[action]
+	if c.Language == LanguageEn {
+		denied = c.actionDeniedEn[action]
+	}
+	if denied != "" {
+		return denied
+	}
+	if msg, ok := defaultDenied[c.Language]; ok {
+		return msg
+	}
+	return defaultDenied[LanguagePtBr]
+}
+
+func isBroadcaster(user *chat.User) bool {
+	return Level(user) == levels[PermissionBroadcaster]
+}
+
+func isModerator(user *chat.User) bool {
+	return Level(user) >= levels[PermissionModerator]
+}
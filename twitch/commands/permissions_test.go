@@ -0,0 +1,83 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowed(t *testing.T) {
+	var c commands.Commands
+	err := c.Load(strings.NewReader(`{
+		"allow-lists": {"amigos": ["acaverna"]},
+		"commands": [
+			{"actions": ["!gh"], "responses": ["/me github"]},
+			{"actions": ["!sub"], "responses": ["/me oi sub"], "permission": "subscriber"},
+			{"actions": ["!vip"], "responses": ["/me oi vip"], "permission": "vip"},
+			{"actions": ["!marquee"], "responses": ["/me marquee"], "permission": "moderator"},
+			{"actions": ["!ban"], "responses": ["/me ban"], "permission": "broadcaster"},
+			{"actions": ["!amigos"], "responses": ["/me oi"], "permission": "amigos"}
+		]}`))
+	assert.NoError(t, err)
+
+	viewer := &chat.User{Name: "viewer"}
+	founder := &chat.User{Name: "founder", Badges: map[string]int{"founder": 0}}
+	vip := &chat.User{Name: "vip", Badges: map[string]int{"vip": 1}}
+	mod := &chat.User{Name: "mod", Badges: map[string]int{"moderator": 1, "subscriber": 3}}
+	broadcaster := &chat.User{Name: "moniquelive", Badges: map[string]int{"broadcaster": 1}}
+	friend := &chat.User{Name: "AcaVerna"}
+
+	var tt = []struct {
+		name     string
+		action   string
+		user     *chat.User
+		expected bool
+	}{
+		{"everyone", "!gh", viewer, true},
+		{"unknown command", "!nope", viewer, true},
+		{"subscriber, viewer", "!sub", viewer, false},
+		{"subscriber, founder", "!sub", founder, true},
+		{"vip, subscriber", "!vip", founder, false},
+		{"vip, vip", "!vip", vip, true},
+		{"vip, moderator", "!vip", mod, true},
+		{"moderator, vip", "!marquee", vip, false},
+		{"moderator, moderator", "!marquee", mod, true},
+		{"broadcaster, moderator", "!ban", mod, false},
+		{"broadcaster, broadcaster", "!ban", broadcaster, true},
+		{"allow-list, listed", "!amigos", friend, true},
+		{"allow-list, not listed", "!amigos", mod, false},
+		{"allow-list, broadcaster", "!amigos", broadcaster, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, c.Allowed(tc.action, tc.user))
+		})
+	}
+}
+
+func TestDenied(t *testing.T) {
+	var tt = []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{"default", `{"commands": [{"actions": ["!ban"]}]}`,
+			"Desculpa ai {{ .Sender.DisplayName }}, esse não é pra você!"},
+		{"default in english", `{"language": "en", "commands": [{"actions": ["!ban"]}]}`,
+			"Sorry {{ .Sender.DisplayName }}, you can't use that one!"},
+		{"per command", `{"commands": [{"actions": ["!ban"], "negado": "só da Mo!", "denied": "Mo only!"}]}`,
+			"só da Mo!"},
+		{"per command in english", `{"language": "en", "commands": [{"actions": ["!ban"], "negado": "só da Mo!", "denied": "Mo only!"}]}`,
+			"Mo only!"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c commands.Commands
+			assert.NoError(t, c.Load(strings.NewReader(tc.json)))
+			assert.Equal(t, tc.expected, c.Denied("!ban"))
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/poll"
+)
+
+const (
+	pollsRedisKey       = "twitch-bot:twitch:polls" // hash number x PollResult
+	pollsNextRedisKey   = "twitch-bot:twitch:polls:next"
+	defaultPollDuration = time.Minute
+	maxPollOptions      = 10
+)
+
+var polls *poll.Runner
+
+// opening keeps !poll and !skip from running at the same time, the overlay
+// only has room for one of them.
+var opening sync.Mutex
+
+// SetPolls sets where the !poll votes go.
+func SetPolls(r *poll.Runner) {
+	polls = r
+}
+
+// PollResult is a closed poll, as kept in the history.
+type PollResult struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Options   []string  `json:"options"`
+	Votes     []int     `json:"votes"`
+	Winner    string    `json:"winner,omitempty"`
+	StartedBy string    `json:"startedBy"`
+	ClosedAt  time.Time `json:"closedAt"`
+}
+
+func (r PollResult) String() string {
+	winner := "empate"
+	if r.Winner != "" {
+		winner = "venceu " + r.Winner
+	}
+	return fmt.Sprintf("Enquete #%v %q: %v — %v", r.Number, r.Title, standings(r.Options, r.Votes), winner)
+}
+
+// Poll handles !poll:
+//
+//	!poll "pergunta" opção 1 | opção 2 | opção 3 [60s]
+//	!poll           how the open poll stands
+//	!poll end       closes it now
+//	!poll cancel    closes it without a result
+func (c Commands) Poll(user *chat.User, cmdLine string) string {
+	if polls == nil {
+		return "Enquetes indisponíveis..."
+	}
+	switch strings.ToLower(strings.TrimSpace(cmdLine)) {
+	case "":
+		tally, ok := polls.Current()
+		if !ok {
+			return c.Ajuda("poll")
+		}
+		return pollStatus(tally)
+	case "end", "fim":
+		if _, err := polls.Close(); err != nil {
+			return "Aaaaa " + err.Error()
+		}
+		return ""
+	case "cancel", "cancela":
+		if _, err := polls.Cancel(); err != nil {
+			return "Aaaaa " + err.Error()
+		}
+		return "Enquete cancelada"
+	}
+	title, options, duration, err := parsePoll(cmdLine)
+	if err != nil {
+		return fmt.Sprintf("%v. %v", err, c.Ajuda("poll"))
+	}
+	opening.Lock()
+	defer opening.Unlock()
+	if _, ok := polls.Current(); ok {
+		return "Aaaaa " + poll.ErrRunning.Error()
+	}
+	if skipPoll != nil {
+		if _, ok := skipPoll.Current(); ok {
+			return "Aaaaa tem uma votação de !skip aberta, espera ela acabar"
+		}
+	}
+	number, err := red.Incr(pollsNextRedisKey).Result()
+	if err != nil {
+		return "Erro abrindo a enquete: " + err.Error()
+	}
+	tally, err := polls.Start(poll.Poll{
+		ID:        strconv.FormatInt(number, 10),
+		Title:     title,
+		Options:   options,
+		Duration:  duration,
+		StartedBy: user.Name,
+	})
+	if err != nil {
+		return "Aaaaa " + err.Error()
+	}
+	return fmt.Sprintf("Enquete #%v aberta por %v: %q %v — vote com !vote <número> ou digitando a opção",
+		tally.ID, FormatDuration(duration), title, numbered(options))
+}
+
+// Vote handles !vote 2 and !vote <opção>. Votes that count say nothing.
+func (c Commands) Vote(user *chat.User, cmdLine string) string {
+	if polls == nil {
+		return "Enquetes indisponíveis..."
+	}
+	tally, ok := polls.Current()
+	if !ok {
+		return "Nenhuma enquete aberta no momento"
+	}
+	option := pollOption(tally.Options, cmdLine)
+	if option < 0 {
+		return fmt.Sprintf("@%v, as opções são: %v", user.DisplayName, numbered(tally.Options))
+	}
+	if _, err := polls.Vote(strings.ToLower(user.Name), option); err != nil {
+		return "Aaaaa " + err.Error()
+	}
+	return ""
+}
+
+// PollVote counts text as a vote when it is one of the options of the open
+// poll, telling if it did.
+func PollVote(user *chat.User, text string) bool {
+	if polls == nil {
+		return false
+	}
+	tally, ok := polls.Current()
+	if !ok {
+		return false
+	}
+	option := optionByName(tally.Options, text)
+	if option < 0 {
+		return false
+	}
+	_, err := polls.Vote(strings.ToLower(user.Name), option)
+	return err == nil
+}
+
+// PollClosed keeps the result in the history and returns it, to be said in
+// chat.
+func PollClosed(tally poll.Tally) string {
+	number, _ := strconv.Atoi(tally.ID)
+	result := PollResult{
+		Number:    number,
+		Title:     tally.Title,
+		Options:   tally.Options,
+		Votes:     tally.Votes,
+		StartedBy: tally.StartedBy,
+		ClosedAt:  time.Now(),
+	}
+	if tally.Winner != poll.NoWinner {
+		result.Winner = tally.Options[tally.Winner]
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Errorln("PollClosed > Marshal:", err)
+	} else if err := red.HSet(pollsRedisKey, tally.ID, body).Err(); err != nil {
+		log.Errorln("PollClosed > HSet:", err)
+	}
+	return "Fim da " + result.String()
+}
+
+// PollResults handles !resultado [número]: a past poll, the last one by
+// default.
+func (c Commands) PollResults(cmdLine string) string {
+	history := PollHistory()
+	if len(history) == 0 {
+		return "Nenhuma enquete ainda..."
+	}
+	if cmdLine = strings.TrimPrefix(strings.TrimSpace(cmdLine), "#"); cmdLine == "" {
+		return history[len(history)-1].String()
+	}
+	number, err := strconv.Atoi(cmdLine)
+	if err != nil {
+		return c.Ajuda("resultado")
+	}
+	for _, result := range history {
+		if result.Number == number {
+			return result.String()
+		}
+	}
+	return fmt.Sprintf("Enquete #%v não existe...", number)
+}
+
+// PollHistory returns every closed poll, by number.
+func PollHistory() (history []PollResult) {
+	for field, body := range red.HGetAll(pollsRedisKey).Val() {
+		var r PollResult
+		if err := json.Unmarshal([]byte(body), &r); err != nil {
+			log.Errorf("PollHistory > enquete %v: %v", field, err)
+			continue
+		}
+		history = append(history, r)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Number < history[j].Number })
+	return
+}
+
+// parsePoll reads `"pergunta" opção 1 | opção 2 [60s]`.
+func parsePoll(cmdLine string) (title string, options []string, duration time.Duration, err error) {
+	cmdLine = strings.TrimSpace(cmdLine)
+	if !strings.HasPrefix(cmdLine, `"`) && !strings.HasPrefix(cmdLine, "“") {
+		return "", nil, 0, errors.New("A pergunta vai entre aspas")
+	}
+	_, size := firstRune(cmdLine)
+	end := strings.IndexAny(cmdLine[size:], `"”`)
+	if end < 0 {
+		return "", nil, 0, errors.New("Faltou fechar as aspas")
+	}
+	title = strings.TrimSpace(cmdLine[size : size+end])
+	rest := strings.TrimSpace(cmdLine[size+end:])
+	_, size = firstRune(rest)
+	rest = strings.TrimSpace(rest[size:])
+
+	duration = defaultPollDuration
+	if fields := strings.Fields(rest); len(fields) > 0 {
+		if d, err := time.ParseDuration(fields[len(fields)-1]); err == nil && d > 0 {
+			duration = d
+			rest = strings.TrimSpace(strings.TrimSuffix(rest, fields[len(fields)-1]))
+		}
+	}
+	for _, option := range strings.Split(rest, "|") {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	switch {
+	case title == "":
+		return "", nil, 0, errors.New("Faltou a pergunta")
+	case len(options) < 2:
+		return "", nil, 0, errors.New("Precisa de pelo menos 2 opções")
+	case len(options) > maxPollOptions:
+		return "", nil, 0, fmt.Errorf("No máximo %v opções", maxPollOptions)
+	}
+	return
+}
+
+func firstRune(s string) (rune, int) {
+	for _, r := range s {
+		return r, len(string(r))
+	}
+	return 0, 0
+}
+
+// pollOption takes the number (from 1) or the name of an option.
+func pollOption(options []string, choice string) int {
+	choice = strings.TrimSpace(choice)
+	if number, err := strconv.Atoi(choice); err == nil {
+		if number < 1 || number > len(options) {
+			return -1
+		}
+		return number - 1
+	}
+	return optionByName(options, choice)
+}
+
+func optionByName(options []string, name string) int {
+	name = strings.TrimSpace(name)
+	for i, option := range options {
+		if strings.EqualFold(option, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func pollStatus(tally poll.Tally) string {
+	left := time.Until(tally.EndsAt).Truncate(time.Second)
+	return fmt.Sprintf("Enquete #%v %q: %v — faltam %v", tally.ID, tally.Title, standings(tally.Options, tally.Votes), FormatDuration(left))
+}
+
+func numbered(options []string) string {
+	var list []string
+	for i, option := range options {
+		list = append(list, fmt.Sprintf("%v) %v", i+1, option))
+	}
+	return strings.Join(list, " ")
+}
+
+func standings(options []string, votes []int) string {
+	total := 0
+	for _, v := range votes {
+		total += v
+	}
+	var list []string
+	for i, option := range options {
+		percent := 0
+		if total > 0 {
+			percent = votes[i] * 100 / total
+		}
+		list = append(list, fmt.Sprintf("%v) %v: %v (%v%%)", i+1, option, votes[i], percent))
+	}
+	return strings.Join(list, " | ")
+}
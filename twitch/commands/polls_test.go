@@ -0,0 +1,116 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/poll"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollParsing(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!poll"], "ajuda": "abre enquete", "responses": ["{{ .Command.Poll .Sender .CmdLine }}"]}
+	]}`)))
+	red.Del("twitch-bot:twitch:polls:next")
+	runner := poll.New(clock.NewFake(time.Unix(0, 0)), func(poll.Tally) {}, func(poll.Tally) {})
+	commands.SetPolls(runner)
+	defer commands.SetPolls(nil)
+	mod := &chat.User{Name: "mod"}
+
+	var tt = []struct {
+		name     string
+		cmdLine  string
+		expected string
+	}{
+		{"no quotes", `qual linguagem? go | elm`, `A pergunta vai entre aspas. !poll: abre enquete (sinônimos: !poll)`},
+		{"unclosed quotes", `"qual linguagem? go | elm`, `Faltou fechar as aspas. !poll: abre enquete (sinônimos: !poll)`},
+		{"one option", `"qual linguagem?" go`, `Precisa de pelo menos 2 opções. !poll: abre enquete (sinônimos: !poll)`},
+		{"default duration", `"qual linguagem?" go | elm | rust`, `Enquete #1 aberta por 1 minuto: "qual linguagem?" 1) go 2) elm 3) rust — vote com !vote <número> ou digitando a opção`},
+		{"already open", `"outra?" sim | não`, `Aaaaa já tem uma votação aberta`},
+		{"cancel", `cancel`, `Enquete cancelada`},
+		{"smart quotes and duration", `“vai ter live amanhã?” sim | não 2m`, `Enquete #2 aberta por 2 minutos: "vai ter live amanhã?" 1) sim 2) não — vote com !vote <número> ou digitando a opção`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, c.Poll(mod, tc.cmdLine))
+		})
+	}
+	_, _ = runner.Cancel()
+}
+
+func TestPollVoting(t *testing.T) {
+	var c commands.Commands
+	red.Del("twitch-bot:twitch:polls", "twitch-bot:twitch:polls:next")
+	clk := clock.NewFake(time.Unix(0, 0))
+	var said []string
+	runner := poll.New(clk, func(poll.Tally) {}, func(tally poll.Tally) {
+		said = append(said, commands.PollClosed(tally))
+	})
+	commands.SetPolls(runner)
+	defer commands.SetPolls(nil)
+
+	alice := &chat.User{Name: "alice", DisplayName: "Alice"}
+	bob := &chat.User{Name: "bob", DisplayName: "Bob"}
+	carol := &chat.User{Name: "carol", DisplayName: "Carol"}
+
+	assert.Equal(t, "Nenhuma enquete aberta no momento", c.Vote(alice, "1"))
+	assert.False(t, commands.PollVote(alice, "go"))
+	assert.Equal(t, "Nenhuma enquete ainda...", c.PollResults(""))
+
+	c.Poll(alice, `"qual linguagem?" Go | Elm | Rust 30s`)
+	assert.Equal(t, "", c.Vote(alice, "2"))
+	assert.Equal(t, "", c.Vote(bob, "elm"))
+	assert.Equal(t, "@Carol, as opções são: 1) Go 2) Elm 3) Rust", c.Vote(carol, "4"))
+	assert.True(t, commands.PollVote(carol, "go"))
+	assert.False(t, commands.PollVote(carol, "go é a melhor"))
+	assert.True(t, commands.PollVote(alice, "GO"), "changing the vote")
+
+	clk.Advance(30 * time.Second)
+	runner.Tick()
+	expected := `Enquete #1 "qual linguagem?": 1) Go: 2 (66%) | 2) Elm: 1 (33%) | 3) Rust: 0 (0%) — venceu Go`
+	assert.Equal(t, []string{"Fim da " + expected}, said)
+
+	c.Poll(alice, `"tabs ou espaços?" tabs | espaços`)
+	c.Vote(alice, "1")
+	c.Vote(bob, "2")
+	c.Poll(alice, "end")
+
+	if history := commands.PollHistory(); assert.Len(t, history, 2) {
+		assert.Equal(t, "alice", history[0].StartedBy)
+		assert.Equal(t, "", history[1].Winner)
+	}
+	assert.Equal(t, expected, c.PollResults("#1"))
+	assert.Equal(t, `Enquete #2 "tabs ou espaços?": 1) tabs: 1 (50%) | 2) espaços: 1 (50%) — empate`, c.PollResults(""))
+	assert.Equal(t, "Enquete #3 não existe...", c.PollResults("3"))
+}
+
+func TestPollAndSkipTakeTurns(t *testing.T) {
+	var c commands.Commands
+	red.Del("twitch-bot:twitch:polls", "twitch-bot:twitch:polls:next")
+	clk := clock.NewFake(time.Unix(0, 0))
+	polls := poll.New(clk, func(poll.Tally) {}, func(poll.Tally) {})
+	skips := poll.New(clk, func(poll.Tally) {}, func(poll.Tally) {})
+	commands.SetPolls(polls)
+	defer commands.SetPolls(nil)
+	commands.SetSkipPoll(skips)
+	defer commands.SetSkipPoll(nil)
+
+	alice := &chat.User{Name: "alice", DisplayName: "Alice"}
+	c.Poll(alice, `"qual linguagem?" Go | Elm`)
+	assert.Equal(t, "Aaaaa tem uma enquete aberta, o !skip fica pra depois", c.SkipMusic("bob", 10))
+	_, ok := skips.Current()
+	assert.False(t, ok)
+
+	c.Poll(alice, "end")
+	c.SkipMusic("bob", 10)
+	assert.Equal(t, "Aaaaa tem uma votação de !skip aberta, espera ela acabar", c.Poll(alice, `"tabs?" sim | não`))
+	_, ok = polls.Current()
+	assert.False(t, ok)
+	commands.CancelSkipPoll()
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/nicklaw5/helix"
+)
+
+const (
+	quotesRedisKey       = "twitch-bot:twitch:quotes" // hash number x Quote
+	quotesNextRedisKey   = "twitch-bot:twitch:quotes:next"
+	defaultQuoteAuthor   = "moniquelive"
+	quoteDateFormat      = "02/01/2006"
+	quoteSearchMaxResult = 5
+)
+
+// Quote is a memorable line said on stream.
+type Quote struct {
+	Number  int       `json:"number"`
+	Text    string    `json:"text"`
+	Author  string    `json:"author"`
+	AddedBy string    `json:"addedBy"`
+	Game    string    `json:"game,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Date    time.Time `json:"date"`
+}
+
+func (q Quote) String() string {
+	context := q.Date.Format(quoteDateFormat)
+	if q.Game != "" {
+		context = q.Game + ", " + context
+	}
+	return fmt.Sprintf("#%v: %q — %v (%v)", q.Number, q.Text, q.Author, context)
+}
+
+// Quote handles !quote:
+//
+//	!quote                  random quote
+//	!quote 42               quote number 42
+//	!quote search <texto>   quotes with texto (in the text or the author)
+//	!quote add [@autor] <texto>  (mods; replying to a message saves it)
+//	!quote del 42           (mods)
+func (c Commands) Quote(user *chat.User, cmdLine string, reply *chat.Reply) string {
+	args := strings.SplitN(strings.TrimSpace(cmdLine), " ", 2)
+	rest := ""
+	if len(args) > 1 {
+		rest = strings.TrimSpace(args[1])
+	}
+	switch sub := strings.ToLower(args[0]); sub {
+	case "":
+		quotes := Quotes()
+		if len(quotes) == 0 {
+			return "Nenhuma quote ainda... :("
+		}
+		return quotes[rand.Intn(len(quotes))].String()
+	case "add":
+		if !isModerator(user) {
+			return c.Ajuda("quote")
+		}
+		quote, err := AddQuote(user, rest, reply)
+		if err != nil {
+			return "Erro salvando quote: " + err.Error()
+		}
+		return "Quote salva! " + quote.String()
+	case "del", "rm":
+		if !isModerator(user) {
+			return c.Ajuda("quote")
+		}
+		number, err := strconv.Atoi(rest)
+		if err != nil {
+			return c.Ajuda("quote")
+		}
+		if red.HDel(quotesRedisKey, strconv.Itoa(number)).Val() == 0 {
+			return fmt.Sprintf("Quote #%v não existe...", number)
+		}
+		log.Infof("quote del #%v por %v", number, user.Name)
+		return fmt.Sprintf("Quote #%v apagada", number)
+	case "search", "busca":
+		if rest == "" {
+			return c.Ajuda("quote")
+		}
+		found := SearchQuotes(rest)
+		if len(found) == 0 {
+			return fmt.Sprintf("Nenhuma quote com %q...", rest)
+		}
+		if len(found) > 1 {
+			var numbers []string
+			for i, q := range found {
+				if i == quoteSearchMaxResult {
+					numbers = append(numbers, "...")
+					break
+				}
+				numbers = append(numbers, "#"+strconv.Itoa(q.Number))
+			}
+			return fmt.Sprintf("%v (também: %v)", found[0], strings.Join(numbers[1:], " "))
+		}
+		return found[0].String()
+	default:
+		number, err := strconv.Atoi(sub)
+		if err != nil {
+			return c.Ajuda("quote")
+		}
+		quote, ok := QuoteByNumber(number)
+		if !ok {
+			return fmt.Sprintf("Quote #%v não existe...", number)
+		}
+		return quote.String()
+	}
+}
+
+// AddQuote saves text (or the message being replied to) with the game and
+// title the channel has right now.
+func AddQuote(user *chat.User, text string, reply *chat.Reply) (Quote, error) {
+	quote := Quote{
+		Author:  defaultQuoteAuthor,
+		AddedBy: user.Name,
+		Date:    time.Now(),
+	}
+	switch {
+	case text == "" && reply != nil:
+		quote.Text, quote.Author = reply.Text, reply.User
+	case strings.HasPrefix(text, "@"):
+		split := strings.SplitN(text, " ", 2)
+		if len(split) < 2 {
+			return Quote{}, fmt.Errorf("quote de %v sem texto", split[0])
+		}
+		quote.Author, quote.Text = split[0][1:], strings.TrimSpace(split[1])
+	default:
+		quote.Text = text
+	}
+	if quote.Text == "" {
+		return Quote{}, fmt.Errorf("quote sem texto")
+	}
+	if game, title, err := streamStatus.ChannelInformation(); err != nil {
+		log.Errorln("AddQuote > channelInformation:", err)
+	} else {
+		quote.Game, quote.Title = game, title
+	}
+
+	number, err := red.Incr(quotesNextRedisKey).Result()
+	if err != nil {
+		return Quote{}, err
+	}
+	quote.Number = int(number)
+	body, err := json.Marshal(quote)
+	if err != nil {
+		return Quote{}, err
+	}
+	if err := red.HSet(quotesRedisKey, strconv.Itoa(quote.Number), body).Err(); err != nil {
+		return Quote{}, err
+	}
+	log.Infof("quote add #%v por %v", quote.Number, user.Name)
+	return quote, nil
+}
+
+// Quotes returns every quote, by number.
+func Quotes() (quotes []Quote) {
+	for field, body := range red.HGetAll(quotesRedisKey).Val() {
+		var q Quote
+		if err := json.Unmarshal([]byte(body), &q); err != nil {
+			log.Errorf("Quotes > quote %v: %v", field, err)
+			continue
+		}
+		quotes = append(quotes, q)
+	}
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].Number < quotes[j].Number })
+	return
+}
+
+func QuoteByNumber(number int) (q Quote, ok bool) {
+	body, err := red.HGet(quotesRedisKey, strconv.Itoa(number)).Bytes()
+	if err != nil {
+		return Quote{}, false
+	}
+	if err := json.Unmarshal(body, &q); err != nil {
+		log.Errorf("QuoteByNumber > quote %v: %v", number, err)
+		return Quote{}, false
+	}
+	return q, true
+}
+
+// SearchQuotes returns the quotes with term in the text or in the author.
+func SearchQuotes(term string) (found []Quote) {
+	term = strings.ToLower(term)
+	for _, q := range Quotes() {
+		if strings.Contains(strings.ToLower(q.Text), term) || strings.Contains(strings.ToLower(q.Author), term) {
+			found = append(found, q)
+		}
+	}
+	return
+}
+
+// ExportQuotes writes every quote to w as a JSON array.
+func ExportQuotes(w io.Writer) error {
+	quotes := Quotes()
+	if quotes == nil {
+		quotes = []Quote{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(quotes)
+}
+
+// ChannelInformation returns the game and title of the channel.
+func (helixStatus) ChannelInformation() (game, title string, err error) {
+	client, err := authHelix()
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := client.GetChannelInformation(&helix.GetChannelInformationParams{
+		BroadcasterIDs: []string{MoniqueliveID},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if len(resp.Data.Channels) == 0 {
+		return "", "", fmt.Errorf("canal não encontrado")
+	}
+	return resp.Data.Channels[0].GameName, resp.Data.Channels[0].Title, nil
+}
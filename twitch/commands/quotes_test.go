@@ -0,0 +1,87 @@
+package commands_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStatus struct{}
+
+func (fakeStatus) IsLive() (bool, error) { return true, nil }
+func (fakeStatus) ChannelInformation() (game, title string, err error) {
+	return "Science & Technology", "Go na veia", nil
+}
+
+func TestQuote(t *testing.T) {
+	red.Del("twitch-bot:twitch:quotes", "twitch-bot:twitch:quotes:next")
+	commands.SetStreamStatus(fakeStatus{})
+	defer commands.SetStreamStatus(nil)
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!quote"], "responses": [""], "ajuda": "Quotes"}
+	]}`)))
+	mod := &chat.User{Name: "mod", Badges: map[string]int{"moderator": 1}}
+	viewer := &chat.User{Name: "viewer"}
+	reply := &chat.Reply{User: "Alice", Text: "o bug era eu"}
+	date := regexp.MustCompile(`\d\d/\d\d/\d{4}`)
+
+	var tt = []struct {
+		name     string
+		user     *chat.User
+		cmdLine  string
+		reply    *chat.Reply
+		expected string
+	}{
+		{"no quotes yet", viewer, "", nil, "Nenhuma quote ainda... :("},
+		{"add", mod, "add funciona na minha máquina", nil, `Quote salva! #1: "funciona na minha máquina" — moniquelive (Science & Technology, DATE)`},
+		{"add with author", mod, "add @bob é só um one-liner", nil, `Quote salva! #2: "é só um one-liner" — bob (Science & Technology, DATE)`},
+		{"add replying", mod, "add", reply, `Quote salva! #3: "o bug era eu" — Alice (Science & Technology, DATE)`},
+		{"viewers can't add", viewer, "add oi", nil, "!quote: Quotes (sinônimos: !quote)"},
+		{"by number", viewer, "2", nil, `#2: "é só um one-liner" — bob (Science & Technology, DATE)`},
+		{"missing number", viewer, "42", nil, "Quote #42 não existe..."},
+		{"search", viewer, "search BUG", nil, `#3: "o bug era eu" — Alice (Science & Technology, DATE)`},
+		{"search many", viewer, "busca o", nil, `#1: "funciona na minha máquina" — moniquelive (Science & Technology, DATE) (também: #2 #3)`},
+		{"search nothing", viewer, "search rust", nil, `Nenhuma quote com "rust"...`},
+		{"viewers can't delete", viewer, "del 1", nil, "!quote: Quotes (sinônimos: !quote)"},
+		{"delete", mod, "del 1", nil, "Quote #1 apagada"},
+		{"deleted", viewer, "1", nil, "Quote #1 não existe..."},
+		{"numbers aren't reused", mod, "add @carol tchau", nil, `Quote salva! #4: "tchau" — carol (Science & Technology, DATE)`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			response := c.Quote(tc.user, tc.cmdLine, tc.reply)
+			assert.Equal(t, tc.expected, date.ReplaceAllString(response, "DATE"))
+		})
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, commands.ExportQuotes(&buf))
+	var exported []commands.Quote
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	if assert.Len(t, exported, 3) {
+		assert.Equal(t, 2, exported[0].Number)
+		assert.Equal(t, "Science & Technology", exported[0].Game)
+		assert.Equal(t, "Go na veia", exported[0].Title)
+		assert.Equal(t, "mod", exported[0].AddedBy)
+		assert.Equal(t, "Alice", exported[1].Author)
+	}
+}
+
+func TestAddQuoteBrokenCounter(t *testing.T) {
+	red.Del("twitch-bot:twitch:quotes")
+	red.Set("twitch-bot:twitch:quotes:next", "abc", 0)
+	defer red.Del("twitch-bot:twitch:quotes:next")
+	commands.SetStreamStatus(fakeStatus{})
+	defer commands.SetStreamStatus(nil)
+
+	_, err := commands.AddQuote(&chat.User{Name: "mod"}, "oi", nil)
+	assert.Error(t, err)
+	assert.Empty(t, commands.Quotes())
+}
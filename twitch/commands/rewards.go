@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+// Reward is a channel points reward (one that asks for a text, so it shows
+// up in chat with its custom-reward-id) and what the bot does with it.
+type Reward struct {
+	Name      string   `json:"name"`
+	ID        string   `json:"id"`
+	Action    string   `json:"action"`    // RewardPublish, RewardTemplate, RewardSong or RewardOverlay
+	Topic     string   `json:"topic"`     // publish: events.TopicRewardRedeemed (default) or events.TopicTTSRequested
+	Responses []string `json:"responses"` // template, with the text of the reward in .CmdLine
+	Effect    string   `json:"effect"`    // overlay
+}
+
+const (
+	RewardPublish  = "publish"
+	RewardTemplate = "template"
+	RewardSong     = "song"
+	RewardOverlay  = "overlay"
+
+	unknownRewardsRedisKey = "twitch-bot:twitch:rewards:unknown" // hash id x unknownReward
+)
+
+// unknownReward is the last time a reward nobody configured was seen.
+type unknownReward struct {
+	Count    int64     `json:"count"`
+	User     string    `json:"user"`
+	Text     string    `json:"text"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Reward returns the reward configured with id.
+func (c Commands) Reward(id string) (Reward, bool) {
+	for _, reward := range c.Rewards {
+		if reward.ID == id {
+			return reward, true
+		}
+	}
+	return Reward{}, false
+}
+
+// Event is what a publish, song or overlay reward sends when user redeems it
+// with text.
+func (r Reward) Event(user *chat.User, text string) events.Event {
+	switch {
+	case r.Action == RewardSong:
+		return events.SongRequested{URL: text, User: user.DisplayName}
+	case r.Action == RewardOverlay:
+		return events.OverlayEffect{Effect: r.Effect, User: user.DisplayName, Text: text}
+	case r.Topic == events.TopicTTSRequested:
+		return events.TTSRequested{Text: text, User: user.Name}
+	}
+	return events.RewardRedeemed{Reward: r.Name, RewardID: r.ID, User: user.Name, Text: text}
+}
+
+// UnknownReward remembers a reward id that isn't in rewards, so it can be
+// wired up later (see UnknownRewards).
+func UnknownReward(id string, user *chat.User, text string) {
+	var seen unknownReward
+	if body, err := red.HGet(unknownRewardsRedisKey, id).Bytes(); err == nil {
+		_ = json.Unmarshal(body, &seen)
+	}
+	seen.Count++
+	seen.User, seen.Text, seen.LastSeen = user.Name, text, time.Now()
+	body, err := json.Marshal(seen)
+	if err != nil {
+		log.Errorln("UnknownReward > Marshal:", err)
+		return
+	}
+	red.HSet(unknownRewardsRedisKey, id, body)
+}
+
+// UnknownRewards handles !rewards: the reward ids seen in chat that aren't
+// in rewards yet, most recent first.
+func (c Commands) UnknownRewards() string {
+	type entry struct {
+		id string
+		unknownReward
+	}
+	var unknown []entry
+	for id, body := range red.HGetAll(unknownRewardsRedisKey).Val() {
+		if _, ok := c.Reward(id); ok {
+			red.HDel(unknownRewardsRedisKey, id)
+			continue
+		}
+		e := entry{id: id}
+		if err := json.Unmarshal([]byte(body), &e.unknownReward); err != nil {
+			log.Errorf("UnknownRewards > recompensa %v: %v", id, err)
+			continue
+		}
+		unknown = append(unknown, e)
+	}
+	if len(unknown) == 0 {
+		return "Nenhuma recompensa desconhecida 🎉"
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].LastSeen.After(unknown[j].LastSeen) })
+	var list []string
+	for _, e := range unknown {
+		list = append(list, fmt.Sprintf("%v (%vx, última: %v %q)", e.id, e.Count, e.User, e.Text))
+	}
+	return "Recompensas desconhecidas: " + strings.Join(list, " | ")
+}
@@ -0,0 +1,57 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewards(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"rewards": [
+		{"name": "tts", "id": "tts-id", "action": "publish", "topic": "create_tts"},
+		{"name": "hidrata", "id": "hidrata-id", "action": "publish"},
+		{"name": "spotify", "id": "spotify-id", "action": "song"},
+		{"name": "confete", "id": "confete-id", "action": "overlay", "effect": "confete"}
+	]}`)))
+	alice := &chat.User{Name: "alice", DisplayName: "Alice"}
+
+	var tt = []struct {
+		name     string
+		id       string
+		expected events.Event
+	}{
+		{"tts", "tts-id", events.TTSRequested{Text: "oi", User: "alice"}},
+		{"generic", "hidrata-id", events.RewardRedeemed{Reward: "hidrata", RewardID: "hidrata-id", User: "alice", Text: "oi"}},
+		{"song", "spotify-id", events.SongRequested{URL: "oi", User: "Alice"}},
+		{"overlay", "confete-id", events.OverlayEffect{Effect: "confete", User: "Alice", Text: "oi"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reward, ok := c.Reward(tc.id)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expected, reward.Event(alice, "oi"))
+		})
+	}
+	_, ok := c.Reward("nope")
+	assert.False(t, ok)
+}
+
+func TestUnknownRewards(t *testing.T) {
+	red.Del("twitch-bot:twitch:rewards:unknown")
+	var c commands.Commands
+	assert.Equal(t, "Nenhuma recompensa desconhecida 🎉", c.UnknownRewards())
+
+	commands.UnknownReward("abc", &chat.User{Name: "alice"}, "oi")
+	commands.UnknownReward("abc", &chat.User{Name: "bob"}, "tchau")
+	commands.UnknownReward("def", &chat.User{Name: "carol"}, "hmm")
+	assert.Equal(t, `Recompensas desconhecidas: def (1x, última: carol "hmm") | abc (2x, última: bob "tchau")`, c.UnknownRewards())
+
+	// wired up after a reload: not unknown anymore
+	assert.NoError(t, c.Load(strings.NewReader(`{"rewards": [{"name": "abc", "id": "abc", "action": "song"}]}`)))
+	assert.Equal(t, `Recompensas desconhecidas: def (1x, última: carol "hmm")`, c.UnknownRewards())
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/poll"
+)
+
+// SkipPoll is the vote to skip the song playing, opened by the first !skip.
+type SkipPoll struct {
+	Window   Duration `json:"window"`    // how long the vote stays open
+	Ratio    float64  `json:"ratio"`     // share of the chatters (the roster) that has to vote to skip
+	MinVotes int      `json:"min-votes"` // votes to skip, however empty the chat is
+}
+
+const (
+	SkipOption = iota
+	KeepOption
+
+	defaultSkipWindow = time.Minute
+	skipPollTitle     = "Pular a música?"
+)
+
+var skipPoll *poll.Runner
+
+// SetSkipPoll sets where the !skip votes go.
+func SetSkipPoll(r *poll.Runner) {
+	skipPoll = r
+}
+
+// Quorum is how many votes to skip it takes with viewers in the chat.
+func (s SkipPoll) Quorum(viewers int) int {
+	quorum := int(math.Ceil(s.Ratio * float64(viewers)))
+	if quorum < s.MinVotes {
+		quorum = s.MinVotes
+	}
+	if quorum < 1 {
+		quorum = 1
+	}
+	return quorum
+}
+
+func (s SkipPoll) window() time.Duration {
+	if s.Window <= 0 {
+		return defaultSkipWindow
+	}
+	return time.Duration(s.Window)
+}
+
+// SkipMusic handles !skip, opening the vote when there's none. viewers is
+// the size of the roster.
+func (c Commands) SkipMusic(username string, viewers int) string {
+	if skipPoll == nil {
+		return "Votação indisponível..."
+	}
+	opening.Lock()
+	opened := false
+	if _, ok := skipPoll.Current(); !ok {
+		if polls != nil {
+			if _, ok := polls.Current(); ok {
+				opening.Unlock()
+				return "Aaaaa tem uma enquete aberta, o !skip fica pra depois"
+			}
+		}
+		_, err := skipPoll.Start(poll.Poll{
+			Title:     skipPollTitle,
+			Options:   []string{"pula", "fica"},
+			Quorum:    c.SkipPoll.Quorum(viewers),
+			Duration:  c.SkipPoll.window(),
+			StartedBy: username,
+		})
+		opened = err == nil
+	}
+	opening.Unlock()
+	tally, err := skipPoll.Vote(username, SkipOption)
+	if err != nil {
+		return "Aaaaa " + err.Error()
+	}
+	if opened {
+		return fmt.Sprintf("Votação aberta por %v! %v votos pra pular. !skip ou !fica — %v",
+			FormatDuration(tally.Duration), tally.Quorum, partials(tally))
+	}
+	return "Aaaaa " + partials(tally)
+}
+
+// KeepMusic handles !fica.
+func (c Commands) KeepMusic(username string) string {
+	if skipPoll == nil {
+		return "Votação indisponível..."
+	}
+	tally, err := skipPoll.Vote(username, KeepOption)
+	if err == poll.ErrNoPoll {
+		return "kumaPls ninguém quer pular, a música fica!"
+	}
+	if err != nil {
+		return "kumaPls " + err.Error()
+	}
+	return "kumaPls " + partials(tally)
+}
+
+// CancelSkipPoll drops the vote, e.g. when the song changed before it closed.
+func CancelSkipPoll() {
+	if skipPoll != nil {
+		_, _ = skipPoll.Cancel()
+	}
+}
+
+// SkipPollClosed applies the result of the vote and returns how it went, to
+// be said in chat.
+func SkipPollClosed(tally poll.Tally) string {
+	if tally.Winner != SkipOption {
+		return fmt.Sprintf("A música fica! kumaPls %v (precisava de %v pra pular)", partials(tally), tally.Quorum)
+	}
+	if err := notifyAMQPTopic(events.SongSkip{RequestedBy: "votação"}); err != nil {
+		log.Errorln("SkipPollClosed > notifyAMQPTopic:", err)
+		return "Erro pulando a música: " + err.Error()
+	}
+	return "PULANDO!!!! 💃 " + partials(tally)
+}
+
+func partials(tally poll.Tally) string {
+	return fmt.Sprintf("parciais: (vaza: %v X fica: %v)", tally.Votes[SkipOption], tally.Votes[KeepOption])
+}
@@ -0,0 +1,79 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/poll"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePublisher struct {
+	topics []string
+}
+
+func (p *fakePublisher) Publish(topic string, _ []byte, _ time.Duration) error {
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func TestSkipPollQuorum(t *testing.T) {
+	var tt = []struct {
+		name     string
+		poll     commands.SkipPoll
+		viewers  int
+		expected int
+	}{
+		{"empty config", commands.SkipPoll{}, 100, 1},
+		{"ratio", commands.SkipPoll{Ratio: 0.1, MinVotes: 3}, 45, 5},
+		{"min votes", commands.SkipPoll{Ratio: 0.1, MinVotes: 3}, 10, 3},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.poll.Quorum(tc.viewers))
+		})
+	}
+}
+
+func TestSkipMusic(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"skip-poll": {"window": "30s", "ratio": 0.1, "min-votes": 2}}`)))
+	publisher := &fakePublisher{}
+	commands.SetPublisher(publisher)
+	defer commands.SetPublisher(nil)
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	var said []string
+	runner := poll.New(clk, func(poll.Tally) {}, func(tally poll.Tally) {
+		said = append(said, commands.SkipPollClosed(tally))
+	})
+	commands.SetSkipPoll(runner)
+	defer commands.SetSkipPoll(nil)
+
+	assert.Equal(t, "kumaPls ninguém quer pular, a música fica!", c.KeepMusic("carol"))
+	assert.Equal(t, "Votação aberta por 30 segundos! 2 votos pra pular. !skip ou !fica — parciais: (vaza: 1 X fica: 0)", c.SkipMusic("alice", 10))
+	assert.Equal(t, "kumaPls parciais: (vaza: 1 X fica: 1)", c.KeepMusic("carol"))
+	assert.Equal(t, "Aaaaa parciais: (vaza: 2 X fica: 1)", c.SkipMusic("bob", 10))
+
+	clk.Advance(30 * time.Second)
+	runner.Tick()
+	assert.Equal(t, []string{"PULANDO!!!! 💃 parciais: (vaza: 2 X fica: 1)"}, said)
+	assert.Equal(t, []string{events.TopicSongSkip}, publisher.topics)
+
+	// a new song cancels the vote
+	c.SkipMusic("alice", 100)
+	commands.CancelSkipPoll()
+	clk.Advance(time.Minute)
+	runner.Tick()
+	assert.Len(t, said, 1)
+
+	c.SkipMusic("alice", 100)
+	clk.Advance(time.Minute)
+	runner.Tick()
+	assert.Equal(t, "A música fica! kumaPls parciais: (vaza: 1 X fica: 0) (precisava de 10 pra pular)", said[1])
+	assert.Len(t, publisher.topics, 1)
+}
@@ -0,0 +1,301 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/spotify"
+
+	"github.com/go-redis/redis"
+)
+
+// SongRequests are the rules of the song request queue (!sr and the channel
+// points reward).
+type SongRequests struct {
+	MaxPerUser    int      `json:"max-per-user"` // requests of the same user waiting, 0 for no limit
+	MaxDuration   Duration `json:"max-duration"` // 0 for no limit
+	AllowExplicit bool     `json:"allow-explicit"`
+	BannedArtists []string `json:"banned-artists"` // names or spotify ids
+	BannedTracks  []string `json:"banned-tracks"`  // spotify ids or links
+	Lead          Duration `json:"lead"`           // how long before the end of the song the next request goes to spotify
+}
+
+// SongRequest is a song waiting in the bot queue.
+type SongRequest struct {
+	TrackID     string    `json:"trackId"`
+	Title       string    `json:"title"`
+	Artists     []string  `json:"artists"`
+	ArtistIDs   []string  `json:"artistIds"`
+	Duration    Duration  `json:"duration"`
+	Explicit    bool      `json:"explicit"`
+	User        string    `json:"user"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+func (r SongRequest) String() string {
+	return fmt.Sprintf("%q by %q (%v)", r.Title, strings.Join(r.Artists, ","), FormatDuration(time.Duration(r.Duration)))
+}
+
+const (
+	songQueueRedisKey      = "twitch-bot:twitch:song-requests:queue"    // list of SongRequest
+	songAttemptsRedisKey   = "twitch-bot:twitch:song-requests:attempts" // hash SongRequest x failed forwards
+	songQueueMaxListed     = 5
+	songForwardMaxAttempts = 12 // about a minute of songqueue ticks
+	defaultSongLead        = 15 * time.Second
+)
+
+// ErrSongDropped wraps the errors of requests taken out of the queue because
+// spotify won't take them.
+var ErrSongDropped = errors.New("pedido de música descartado")
+
+// ErrSongRejected wraps the errors of requests that break the rules; like
+// ErrInvalidSongURL, they are not worth retrying.
+var ErrSongRejected = errors.New("pedido de música recusado")
+
+type songRejection string
+
+func (r songRejection) Error() string        { return string(r) }
+func (r songRejection) Is(target error) bool { return target == ErrSongRejected }
+
+func rejectSong(format string, args ...interface{}) error {
+	return songRejection(fmt.Sprintf(format, args...))
+}
+
+// LeadTime is how long before the end of the song the next request goes to
+// spotify.
+func (s SongRequests) LeadTime() time.Duration {
+	if s.Lead <= 0 {
+		return defaultSongLead
+	}
+	return time.Duration(s.Lead)
+}
+
+// check tells why r can't join queue, if it can't.
+func (s SongRequests) check(r SongRequest, queue []SongRequest) error {
+	for _, banned := range s.BannedTracks {
+		if trackID(banned) == r.TrackID {
+			return rejectSong("Essa música está banida da fila, @%v", r.User)
+		}
+	}
+	for _, banned := range s.BannedArtists {
+		for i, artist := range r.Artists {
+			if strings.EqualFold(banned, artist) || (i < len(r.ArtistIDs) && banned == r.ArtistIDs[i]) {
+				return rejectSong("%v está banido(a) da fila, @%v", artist, r.User)
+			}
+		}
+	}
+	if r.Explicit && !s.AllowExplicit {
+		return rejectSong("Música explícita não pode, @%v 🙊", r.User)
+	}
+	if s.MaxDuration > 0 && r.Duration > s.MaxDuration {
+		return rejectSong("Música muito longa (%v, máximo %v), @%v",
+			FormatDuration(time.Duration(r.Duration)), FormatDuration(time.Duration(s.MaxDuration)), r.User)
+	}
+	mine := 0
+	for _, queued := range queue {
+		if queued.TrackID == r.TrackID {
+			return rejectSong("%q já está na fila, @%v", r.Title, r.User)
+		}
+		if strings.EqualFold(queued.User, r.User) {
+			mine++
+		}
+	}
+	if s.MaxPerUser > 0 && mine >= s.MaxPerUser {
+		return rejectSong("Você já tem %v música(s) na fila, @%v. Espera tocar!", mine, r.User)
+	}
+	return nil
+}
+
+// SongRequest handles !sr <link do spotify>.
+func (c Commands) SongRequest(user *chat.User, songUrl string) string {
+	if strings.TrimSpace(songUrl) == "" {
+		return c.Ajuda("sr")
+	}
+	msg, err := c.RequestSong(user.DisplayName, strings.TrimSpace(songUrl))
+	if err != nil {
+		return err.Error()
+	}
+	return msg
+}
+
+// RequestSong looks songUrl up on spotify and puts it in the bot queue,
+// returning the message to be said in chat. Errors wrapping
+// ErrInvalidSongURL or ErrSongRejected are not worth retrying.
+func (c Commands) RequestSong(displayName, songUrl string) (string, error) {
+	songId := trackID(songUrl)
+	if songId == "" {
+		return "", fmt.Errorf("Música não encontrada:%q: %w", songUrl, ErrInvalidSongURL)
+	}
+
+	client, err := authSpotify()
+	if err != nil {
+		return "", fmt.Errorf("Erro autenticando spotify: %w", err)
+	}
+
+	songInfo, err := client.GetSongInfo(songId)
+	if err != nil {
+		return "", fmt.Errorf("Música não encontrada:%w", err)
+	}
+
+	request := SongRequest{
+		TrackID:     songId,
+		Title:       songInfo.Name,
+		Duration:    Duration(time.Duration(songInfo.DurationMs) * time.Millisecond),
+		Explicit:    songInfo.Explicit,
+		User:        displayName,
+		RequestedAt: time.Now(),
+	}
+	for _, artist := range songInfo.Artists {
+		request.Artists = append(request.Artists, artist.Name)
+		request.ArtistIDs = append(request.ArtistIDs, artist.Id)
+	}
+	position, err := c.QueueSong(request)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Na fila (#%v): %v - @%v", position, request, displayName), nil
+}
+
+// QueueSong puts r at the end of the bot queue, if the rules allow, and
+// returns its position.
+func (c Commands) QueueSong(r SongRequest) (int, error) {
+	if err := c.SongRequests.check(r, SongQueue()); err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+	position, err := red.RPush(songQueueRedisKey, body).Result()
+	if err != nil {
+		return 0, err
+	}
+	log.Infof("pedido de música #%v: %v - %v", position, r, r.User)
+	return int(position), nil
+}
+
+// SongQueue returns the requests waiting, the next one first.
+func SongQueue() (queue []SongRequest) {
+	for _, body := range red.LRange(songQueueRedisKey, 0, -1).Val() {
+		var r SongRequest
+		if err := json.Unmarshal([]byte(body), &r); err != nil {
+			log.Errorln("SongQueue > Unmarshal:", err)
+			continue
+		}
+		queue = append(queue, r)
+	}
+	return
+}
+
+// ForwardSongRequest sends the next request to the spotify queue and takes it
+// out of ours. It returns nil when there's nothing waiting.
+func ForwardSongRequest(enqueue func(trackID string) error) (*SongRequest, error) {
+	body, err := red.LIndex(songQueueRedisKey, 0).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r SongRequest
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		red.LRem(songQueueRedisKey, 1, body)
+		return nil, err
+	}
+	if err := enqueue(r.TrackID); err != nil {
+		var status spotify.StatusError
+		attempts := red.HIncrBy(songAttemptsRedisKey, body, 1).Val()
+		if (errors.As(err, &status) && status.Permanent()) || attempts >= songForwardMaxAttempts {
+			red.LRem(songQueueRedisKey, 1, body)
+			red.HDel(songAttemptsRedisKey, body)
+			return &r, fmt.Errorf("%w: %v", ErrSongDropped, err)
+		}
+		return nil, err
+	}
+	red.LRem(songQueueRedisKey, 1, body)
+	red.HDel(songAttemptsRedisKey, body)
+	return &r, nil
+}
+
+// EnqueueSpotify adds trackID to the spotify queue.
+func EnqueueSpotify(trackID string) error {
+	client, err := authSpotify()
+	if err != nil {
+		return fmt.Errorf("Erro autenticando spotify: %w", err)
+	}
+	return client.EnqueueSong(trackID)
+}
+
+// ShowSongQueue handles !queue.
+func (c Commands) ShowSongQueue() string {
+	queue := SongQueue()
+	if len(queue) == 0 {
+		return "Fila vazia! Pede a sua com !sr <link do spotify>"
+	}
+	var lines []string
+	for i, r := range queue {
+		if i == songQueueMaxListed {
+			lines = append(lines, fmt.Sprintf("(+%v)", len(queue)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%v. %v - @%v", i+1, r, r.User))
+	}
+	return "Fila: " + strings.Join(lines, " | ")
+}
+
+// WrongSong handles !wrongsong: takes the last request of user out of the
+// queue.
+func (c Commands) WrongSong(user *chat.User) string {
+	bodies := red.LRange(songQueueRedisKey, 0, -1).Val()
+	for i := len(bodies) - 1; i >= 0; i-- {
+		var r SongRequest
+		if err := json.Unmarshal([]byte(bodies[i]), &r); err != nil {
+			continue
+		}
+		if !strings.EqualFold(r.User, user.DisplayName) {
+			continue
+		}
+		red.LRem(songQueueRedisKey, -1, bodies[i])
+		red.HDel(songAttemptsRedisKey, bodies[i])
+		return fmt.Sprintf("Tirei %v da fila, @%v", r, user.DisplayName)
+	}
+	return fmt.Sprintf("Você não tem músicas na fila, @%v", user.DisplayName)
+}
+
+// ClearSongQueue handles !srclear.
+func (c Commands) ClearSongQueue(user *chat.User) string {
+	count := red.LLen(songQueueRedisKey).Val()
+	red.Del(songQueueRedisKey, songAttemptsRedisKey)
+	log.Infof("fila de músicas (%v) limpa por %v", count, user.Name)
+	return fmt.Sprintf("Fila de músicas limpa (%v pedido(s))", count)
+}
+
+// SkipSong handles !srskip: skips the song playing right away.
+func (c Commands) SkipSong(user *chat.User) string {
+	CancelSkipPoll()
+	if err := notifyAMQPTopic(events.SongSkip{RequestedBy: user.Name}); err != nil {
+		log.Errorln("SkipSong > notifyAMQPTopic:", err)
+		return "Erro pulando a música: " + err.Error()
+	}
+	return fmt.Sprintf("Pulando a música, a pedido de @%v", user.DisplayName)
+}
+
+// trackID takes a spotify track link (https://open.spotify.com/track/ID),
+// uri (spotify:track:ID) or bare id.
+func trackID(song string) string {
+	if strings.HasPrefix(song, "spotify:track:") {
+		return strings.TrimPrefix(song, "spotify:track:")
+	}
+	parsedUrl, err := url.Parse(song)
+	if err != nil {
+		return ""
+	}
+	split := strings.Split(parsedUrl.Path, "/")
+	return split[len(split)-1]
+}
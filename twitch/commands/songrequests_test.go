@@ -0,0 +1,112 @@
+package commands_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueSong(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"song-requests": {
+		"max-per-user": 2,
+		"max-duration": "7m",
+		"banned-artists": ["Nickelback", "0gxyHStUsqpMadRV0Di1Qt"],
+		"banned-tracks": ["https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc"]
+	}}`)))
+	red.Del("twitch-bot:twitch:song-requests:queue")
+
+	song := func(id, artist string, minutes int, user string) commands.SongRequest {
+		return commands.SongRequest{
+			TrackID:   id,
+			Title:     "música " + id,
+			Artists:   []string{artist},
+			ArtistIDs: []string{"id-" + artist},
+			Duration:  commands.Duration(time.Duration(minutes) * time.Minute),
+			User:      user,
+		}
+	}
+	explicit := song("e", "Rouge", 3, "bob")
+	explicit.Explicit = true
+	bannedByID := song("f", "Rick Astley", 3, "bob")
+	bannedByID.ArtistIDs = []string{"0gxyHStUsqpMadRV0Di1Qt"}
+
+	var tt = []struct {
+		name     string
+		request  commands.SongRequest
+		position int
+		rejected bool
+	}{
+		{"first request", song("a", "Rouge", 3, "alice"), 1, false},
+		{"same song again", song("a", "Rouge", 3, "bob"), 0, true},
+		{"second request", song("b", "Rouge", 3, "Alice"), 2, false},
+		{"over the per user limit", song("c", "Rouge", 3, "alice"), 0, true},
+		{"too long", song("d", "Rouge", 8, "bob"), 0, true},
+		{"explicit", explicit, 0, true},
+		{"banned artist", song("g", "nickelback", 3, "bob"), 0, true},
+		{"banned artist id", bannedByID, 0, true},
+		{"banned track", song("4uLU6hMCjMI75M1A2tKUQC", "Rouge", 3, "bob"), 0, true},
+		{"other user", song("h", "Rouge", 3, "bob"), 3, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			position, err := c.QueueSong(tc.request)
+			assert.Equal(t, tc.position, position)
+			assert.Equal(t, tc.rejected, errors.Is(err, commands.ErrSongRejected), err)
+		})
+	}
+
+	alice := &chat.User{Name: "alice", DisplayName: "Alice"}
+	assert.Equal(t, `Fila: 1. "música a" by "Rouge" (3 minutos) - @alice | 2. "música b" by "Rouge" (3 minutos) - @Alice | 3. "música h" by "Rouge" (3 minutos) - @bob`, c.ShowSongQueue())
+	assert.Equal(t, `Tirei "música b" by "Rouge" (3 minutos) da fila, @Alice`, c.WrongSong(alice))
+	assert.Len(t, commands.SongQueue(), 2)
+	assert.Equal(t, "Fila de músicas limpa (2 pedido(s))", c.ClearSongQueue(alice))
+	assert.Equal(t, "Você não tem músicas na fila, @Alice", c.WrongSong(alice))
+	assert.Empty(t, commands.SongQueue())
+}
+
+func TestForwardSongRequest(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{}`)))
+	red.Del("twitch-bot:twitch:song-requests:queue", "twitch-bot:twitch:song-requests:attempts")
+	defer red.Del("twitch-bot:twitch:song-requests:queue", "twitch-bot:twitch:song-requests:attempts")
+
+	var tt = []struct {
+		name    string
+		err     error
+		tries   int
+		dropped bool
+		queued  bool
+	}{
+		{"accepted", nil, 1, false, false},
+		{"not found", spotify.StatusError{Code: 404}, 1, true, false},
+		{"no active device", spotify.StatusError{Code: 403}, 1, true, false},
+		{"rate limited", spotify.StatusError{Code: 429}, 11, false, true},
+		{"down", spotify.StatusError{Code: 503}, 12, true, false},
+		{"network", errors.New("timeout"), 12, true, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			defer c.ClearSongQueue(&chat.User{Name: "mod"})
+			_, err := c.QueueSong(commands.SongRequest{TrackID: "a", Title: "música a", User: "alice"})
+			assert.NoError(t, err)
+
+			var r *commands.SongRequest
+			for i := 0; i < tc.tries; i++ {
+				r, err = commands.ForwardSongRequest(func(trackID string) error {
+					assert.Equal(t, "a", trackID)
+					return tc.err
+				})
+			}
+			assert.Equal(t, tc.dropped, errors.Is(err, commands.ErrSongDropped), err)
+			assert.Equal(t, !tc.queued, r != nil, "the request is handed back once it leaves the queue")
+			assert.Equal(t, tc.queued, len(commands.SongQueue()) == 1)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+)
+
+// rankings written by twitch_stats, see twitch_stats/redis.go
+const (
+	statsChattersKeyPrefix = "twitch-bot:twitch_stats:chatters:"
+	statsCommandsKeyPrefix = "twitch-bot:twitch_stats:commands:"
+	topSize                = 5
+)
+
+type statsPeriod struct {
+	key   string
+	label string
+}
+
+var periodAll = statsPeriod{"all", "desde sempre"}
+
+// periodSession is the live going on, or the last one.
+func periodSession() statsPeriod {
+	return statsPeriod{"session:" + red.Get(sessionLastRedisKey).Val(), "nesta live"}
+}
+
+func periodDay(now time.Time) statsPeriod {
+	return statsPeriod{"day:" + now.Format("2006-01-02"), "hoje"}
+}
+
+func statsPeriods() []statsPeriod {
+	return []statsPeriod{periodSession(), periodDay(time.Now()), periodAll}
+}
+
+// parsePeriod understands the period names people type in chat.
+func parsePeriod(name string) (statsPeriod, bool) {
+	switch strings.ToLower(name) {
+	case "", "live", "sessao", "sessão", "session":
+		return periodSession(), true
+	case "hoje", "dia", "today", "day":
+		return periodDay(time.Now()), true
+	case "sempre", "tudo", "all":
+		return periodAll, true
+	}
+	return statsPeriod{}, false
+}
+
+// Stats shows how much user talked in chat or, given a command, how many
+// times it was used (all of its aliases together).
+func (c Commands) Stats(user *chat.User, cmdLine string) string {
+	if cmdLine == "" {
+		return c.chatterStats(user)
+	}
+	fields := strings.Fields(cmdLine)
+	if len(fields) == 0 {
+		return c.Ajuda("stats")
+	}
+	action := fields[0]
+	if action[0] != '!' {
+		action = "!" + action
+	}
+	actions, ok := c.ActionActions[action]
+	if !ok {
+		return fmt.Sprintf("Comando %q não encontrado...", action)
+	}
+	var counts []string
+	for _, period := range statsPeriods() {
+		counts = append(counts, fmt.Sprintf("%v %v", commandCount(actions, period), period.label))
+	}
+	return fmt.Sprintf("%v foi usado %v", actions[0], strings.Join(counts, ", "))
+}
+
+func (c Commands) chatterStats(user *chat.User) string {
+	name := strings.ToLower(user.Name)
+	var counts []string
+	for _, period := range statsPeriods() {
+		key := statsChattersKeyPrefix + period.key
+		count := int64(red.ZScore(key, name).Val())
+		counts = append(counts, fmt.Sprintf("%v %v", count, period.label))
+	}
+	stats := fmt.Sprintf("@%v mandou mensagens: %v", user.DisplayName, strings.Join(counts, ", "))
+	if rank, err := red.ZRevRank(statsChattersKeyPrefix+periodAll.key, name).Result(); err == nil {
+		stats += fmt.Sprintf(" (#%v no ranking)", rank+1)
+	}
+	return stats
+}
+
+// Top shows the leaderboard of chatters or commands in a period (the
+// current live by default).
+func (c Commands) Top(cmdLine string) string {
+	args := strings.Fields(cmdLine)
+	if len(args) == 0 || len(args) > 2 {
+		return c.Ajuda("top")
+	}
+	var periodName string
+	if len(args) == 2 {
+		periodName = args[1]
+	}
+	period, ok := parsePeriod(periodName)
+	if !ok {
+		return fmt.Sprintf("Período %q não existe (use live, hoje ou sempre)", periodName)
+	}
+	var ranking []redis.Z
+	switch strings.ToLower(args[0]) {
+	case "chatters", "chat":
+		ranking = red.ZRevRangeWithScores(statsChattersKeyPrefix+period.key, 0, topSize-1).Val()
+	case "commands", "comandos", "cmds":
+		ranking = c.commandRanking(period)
+	default:
+		return c.Ajuda("top")
+	}
+	if len(ranking) == 0 {
+		return fmt.Sprintf("Ninguém no ranking %v ainda... :(", period.label)
+	}
+	var top []string
+	for i, z := range ranking {
+		top = append(top, fmt.Sprintf("%v. %v (%v)", i+1, z.Member, int64(z.Score)))
+	}
+	return fmt.Sprintf("🏆 Top %v %v: %v", strings.ToLower(args[0]), period.label, strings.Join(top, " "))
+}
+
+// commandRanking folds the aliases into the first action of each command
+// and leaves out whatever isn't a command.
+func (c Commands) commandRanking(period statsPeriod) []redis.Z {
+	totals := make(map[string]float64)
+	for _, z := range red.ZRangeWithScores(statsCommandsKeyPrefix+period.key, 0, -1).Val() {
+		actions, ok := c.ActionActions[z.Member.(string)]
+		if !ok {
+			continue
+		}
+		totals[actions[0]] += z.Score
+	}
+	var ranking []redis.Z
+	for action, total := range totals {
+		ranking = append(ranking, redis.Z{Score: total, Member: action})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		return ranking[i].Member.(string) < ranking[j].Member.(string)
+	})
+	if len(ranking) > topSize {
+		ranking = ranking[:topSize]
+	}
+	return ranking
+}
+
+func commandCount(actions []string, period statsPeriod) (count int64) {
+	key := statsCommandsKeyPrefix + period.key
+	for _, action := range actions {
+		count += int64(red.ZScore(key, action).Val())
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	var c commands.Commands
+	assert.NoError(t, c.Load(strings.NewReader(`{"commands": [
+		{"actions": ["!rainbow", "!r"], "responses": ["/me 🌈"]},
+		{"actions": ["!gh", "!github"], "responses": ["/me github"]},
+		{"actions": ["!top"], "responses": ["/me top"], "ajuda": "Rankings"}
+	]}`)))
+
+	const prefix = "twitch-bot:twitch_stats:"
+	day := "day:" + time.Now().Format("2006-01-02")
+	red.Del(prefix+"commands:session:42", prefix+"commands:"+day, prefix+"commands:all",
+		prefix+"chatters:session:42", prefix+"chatters:"+day, prefix+"chatters:all")
+	red.Set(prefix+"session:last", "42", 0)
+	red.ZAdd(prefix+"commands:session:42",
+		redis.Z{Score: 2, Member: "!rainbow"},
+		redis.Z{Score: 3, Member: "!r"},
+		redis.Z{Score: 4, Member: "!gh"},
+		redis.Z{Score: 9, Member: "!naoexiste"})
+	red.ZAdd(prefix+"commands:"+day, redis.Z{Score: 7, Member: "!r"})
+	red.ZAdd(prefix+"commands:all", redis.Z{Score: 10, Member: "!r"}, redis.Z{Score: 10, Member: "!github"})
+	red.ZAdd(prefix+"chatters:session:42", redis.Z{Score: 5, Member: "alice"}, redis.Z{Score: 8, Member: "bob"})
+	red.ZAdd(prefix+"chatters:all", redis.Z{Score: 50, Member: "alice"}, redis.Z{Score: 80, Member: "bob"})
+
+	alice := &chat.User{Name: "alice", DisplayName: "Alice"}
+	var tt = []struct {
+		name     string
+		response string
+		expected string
+	}{
+		{"own stats", c.Stats(alice, ""), "@Alice mandou mensagens: 5 nesta live, 0 hoje, 50 desde sempre (#2 no ranking)"},
+		{"command stats", c.Stats(alice, "r"), "!rainbow foi usado 5 nesta live, 7 hoje, 10 desde sempre"},
+		{"unknown command", c.Stats(alice, "!naoexiste"), `Comando "!naoexiste" não encontrado...`},
+		{"extra spaces", c.Stats(alice, "  r  hoje"), "!rainbow foi usado 5 nesta live, 7 hoje, 10 desde sempre"},
+		{"only spaces", c.Stats(alice, "   "), `Comando "!stats" não encontrado...`},
+		{"top chatters", c.Top("chatters"), "🏆 Top chatters nesta live: 1. bob (8) 2. alice (5)"},
+		{"top commands", c.Top("comandos"), "🏆 Top comandos nesta live: 1. !rainbow (5) 2. !gh (4)"},
+		{"top commands ties", c.Top("comandos sempre"), "🏆 Top comandos desde sempre: 1. !gh (10) 2. !rainbow (10)"},
+		{"empty ranking", c.Top("chatters hoje"), "Ninguém no ranking hoje ainda... :("},
+		{"unknown period", c.Top("chatters ontem"), `Período "ontem" não existe (use live, hoje ou sempre)`},
+		{"unknown ranking", c.Top("emotes"), "!top: Rankings (sinônimos: !top)"},
+		{"cmds label", c.Actions(), "!gh (4) !rainbow (5) !top"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.response)
+		})
+	}
+}
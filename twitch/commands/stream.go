@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/nicklaw5/helix"
+)
+
+// stream sessions are kept by twitch_stats, see twitch_stats/session.go
+const (
+	sessionRedisKeyPrefix = "twitch-bot:twitch_stats:session:"
+	sessionLiveRedisKey   = sessionRedisKeyPrefix + "live"
+	sessionLastRedisKey   = sessionRedisKeyPrefix + "last"
+
+	StreamPollInterval = time.Minute
+)
+
+// PollStream asks helix whether the channel is live and tells twitch_stats
+// when a stream starts, stops and how many viewers it has.
+func PollStream() error {
+	client, err := authHelix()
+	if err != nil {
+		return err
+	}
+	resp, err := client.GetStreams(&helix.StreamsParams{UserIDs: []string{MoniqueliveID}})
+	if err != nil {
+		return fmt.Errorf("erro no GetStreams: %v", err)
+	}
+	var stream *helix.Stream
+	if len(resp.Data.Streams) > 0 {
+		stream = &resp.Data.Streams[0]
+	}
+	live := red.Get(sessionLiveRedisKey).Val()
+	source := red.HGet(sessionRedisKeyPrefix+live, "source").Val()
+	for _, ev := range StreamEvents(stream, live, source) {
+		if err := notifyAMQPTopic(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamEvents compares what helix says (stream is nil when offline) with
+// the session twitch_stats has live. Sessions started with !live are left
+// alone until someone stops them.
+func StreamEvents(stream *helix.Stream, live, liveSource string) []events.Event {
+	if stream == nil {
+		if live != "" && liveSource == events.StreamSourceHelix {
+			return []events.Event{events.StreamStopped{ID: live}}
+		}
+		return nil
+	}
+	var evs []events.Event
+	if stream.ID != live {
+		evs = append(evs, events.StreamStarted{
+			ID:        stream.ID,
+			Title:     stream.Title,
+			StartedAt: stream.StartedAt,
+			Source:    events.StreamSourceHelix,
+		})
+	}
+	return append(evs, events.StreamViewers{ID: stream.ID, Viewers: stream.ViewerCount})
+}
+
+// StreamStatus is where the commands learn how the stream is going.
+type StreamStatus interface {
+	IsLive() (bool, error)
+	ChannelInformation() (game, title string, err error)
+}
+
+// helixStatus asks helix and the sessions twitch_stats keeps in redis.
+type helixStatus struct{}
+
+var streamStatus StreamStatus = helixStatus{}
+
+// SetStreamStatus replaces where the stream status comes from, e.g. with a
+// stub in the console. nil goes back to helix.
+func SetStreamStatus(s StreamStatus) {
+	if s == nil {
+		s = helixStatus{}
+	}
+	streamStatus = s
+}
+
+// IsLive tells whether there's a stream going on.
+func IsLive() (bool, error) {
+	return streamStatus.IsLive()
+}
+
+// IsLive tells whether there's a stream session, as kept by twitch_stats
+// from PollStream and !live.
+func (helixStatus) IsLive() (bool, error) {
+	live, err := red.Get(sessionLiveRedisKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return live != "", err
+}
+
+// Live tells how the current stream session is going. Moderators can also
+// start and stop sessions by hand: !live start [título] | !live stop
+func (c Commands) Live(user *chat.User, cmdLine string) string {
+	live := red.Get(sessionLiveRedisKey).Val()
+	args := strings.SplitN(cmdLine, " ", 2)
+	switch strings.ToLower(args[0]) {
+	case "":
+		if live == "" {
+			return "Offline... 😴"
+		}
+		session := red.HGetAll(sessionRedisKeyPrefix + live).Val()
+		startedAt, _ := strconv.ParseInt(session["started_at"], 10, 64)
+		peak, _ := strconv.Atoi(session["peak_viewers"])
+		return fmt.Sprintf("🔴 Live %v: %q no ar há %v (pico de %v viewers)",
+			live, session["title"], FormatDuration(time.Since(time.Unix(startedAt, 0))), peak)
+	case "start":
+		if !isModerator(user) {
+			return c.Ajuda("live")
+		}
+		var title string
+		if len(args) > 1 {
+			title = args[1]
+		}
+		now := time.Now()
+		ev := events.StreamStarted{
+			ID:        "cmd-" + now.Format("20060102-150405"),
+			Title:     title,
+			StartedAt: now,
+			Source:    events.StreamSourceCommand,
+		}
+		if err := notifyAMQPTopic(ev); err != nil {
+			return "Erro começando a live: " + err.Error()
+		}
+		return "Começando a live " + ev.ID
+	case "stop":
+		if !isModerator(user) {
+			return c.Ajuda("live")
+		}
+		if live == "" {
+			return "Não tem live rolando..."
+		}
+		if err := notifyAMQPTopic(events.StreamStopped{ID: live}); err != nil {
+			return "Erro terminando a live: " + err.Error()
+		}
+		return "Terminando a live " + live
+	}
+	return c.Ajuda("live")
+}
@@ -0,0 +1,51 @@
+package commands_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/nicklaw5/helix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamEvents(t *testing.T) {
+	startedAt := time.Date(2021, 6, 30, 20, 0, 0, 0, time.UTC)
+	stream := &helix.Stream{ID: "42", Title: "Go!", ViewerCount: 10, StartedAt: startedAt}
+	started := events.StreamStarted{ID: "42", Title: "Go!", StartedAt: startedAt, Source: events.StreamSourceHelix}
+	viewers := events.StreamViewers{ID: "42", Viewers: 10}
+
+	var tt = []struct {
+		name       string
+		stream     *helix.Stream
+		live       string
+		liveSource string
+		expected   []events.Event
+	}{
+		{"offline", nil, "", "", nil},
+		{"goes live", stream, "", "", []events.Event{started, viewers}},
+		{"still live", stream, "42", events.StreamSourceHelix, []events.Event{viewers}},
+		{"goes offline", nil, "42", events.StreamSourceHelix, []events.Event{events.StreamStopped{ID: "42"}}},
+		{"started by command", nil, "cmd-1", events.StreamSourceCommand, nil},
+		{"live replaces command session", stream, "cmd-1", events.StreamSourceCommand, []events.Event{started, viewers}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, commands.StreamEvents(tc.stream, tc.live, tc.liveSource))
+		})
+	}
+}
+
+func TestIsLive(t *testing.T) {
+	red.Del("twitch-bot:twitch_stats:session:live")
+	live, err := commands.IsLive()
+	assert.NoError(t, err)
+	assert.False(t, live)
+
+	red.Set("twitch-bot:twitch_stats:session:live", "42", 0)
+	defer red.Del("twitch-bot:twitch_stats:session:live")
+	live, err = commands.IsLive()
+	assert.NoError(t, err)
+	assert.True(t, live)
+}
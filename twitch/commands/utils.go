@@ -1,12 +1,13 @@
 package commands
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/streadway/amqp"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
 )
 
 func WordWrap(str string, size int) (retval []string) {
@@ -88,28 +89,45 @@ func FormatDuration(duration time.Duration) (format string) {
 	return
 }
 
-func notifyAMQPTopic(topicName, body string) error {
-	amqpURL := os.Getenv("RABBITMQ_URL")
-	conn, err := amqp.Dial(amqpURL)
-	if err != nil {
-		return err
+// Publisher is what the commands need to notify other services.
+type Publisher interface {
+	Publish(topic string, body []byte, ttl time.Duration) error
+}
+
+var publisher Publisher
+
+// SetPublisher sets where notifyAMQPTopic sends its events.
+func SetPublisher(p Publisher) {
+	publisher = p
+}
+
+func notifyAMQPTopic(ev events.Event) error {
+	if publisher == nil {
+		return errors.New("sem publisher AMQP")
 	}
-	defer conn.Close()
-	go func() { <-conn.NotifyClose(make(chan *amqp.Error)) }()
-	channel, err := conn.Channel()
+	body, err := events.Encode(producerName, ev)
 	if err != nil {
 		return err
 	}
-	defer channel.Close()
-	err = channel.Publish("amq.topic", topicName, false, false, amqp.Publishing{
-		ContentType:     "application/json",
-		ContentEncoding: "utf-8",
-		DeliveryMode:    2,
-		Expiration:      "60000",
-		Body:            []byte(body),
-	})
+	return publisher.Publish(ev.Topic(), body, time.Minute)
+}
+
+// Duration is a time.Duration written as "90s" or "15m" in commands.json.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duração deve ser uma string (ex: \"15m\"): %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
 	if err != nil {
 		return err
 	}
+	*d = Duration(parsed)
 	return nil
 }
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
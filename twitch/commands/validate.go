@@ -0,0 +1,343 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+)
+
+// ConfigPath is where the bot reads its commands from.
+const ConfigPath = "./config/commands.json"
+
+// TemplateChecker returns an error when a response template can't be used.
+type TemplateChecker func(text string) error
+
+// ValidationError lists everything wrong with a commands.json.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("commands.json com %d problema(s):\n  %s",
+		len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// Open reads the commands in path and validates them.
+func Open(path string, check TemplateChecker) (Commands, error) {
+	var c Commands
+	file, err := os.Open(path)
+	if err != nil {
+		return c, fmt.Errorf("erro ao abrir %s: %w", path, err)
+	}
+	defer file.Close()
+	if err := c.Load(file); err != nil {
+		return c, fmt.Errorf("erro ao parsear %s: %w", path, err)
+	}
+	c.check = check
+	return c, c.Validate(check)
+}
+
+// Validate looks for duplicated or malformed actions, missing help texts,
+// unknown permissions and broken templates. check may be nil to skip the
+// templates.
+func (c Commands) Validate(check TemplateChecker) error {
+	var problems []string
+	problem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	checkTemplate := func(where, text string) {
+		if check == nil || text == "" {
+			return
+		}
+		if err := check(text); err != nil {
+			problem("%s: template %q: %v", where, text, err)
+		}
+	}
+
+	seen := make(map[string]int)
+	for i, command := range c.Commands {
+		label := fmt.Sprintf("commands[%d]", i)
+		if len(command.Actions) == 0 {
+			problem("%s: sem actions", label)
+		} else {
+			label = command.Actions[0]
+		}
+		for _, action := range command.Actions {
+			if !strings.HasPrefix(action, "!") || len(action) < 2 {
+				problem("%s: action %q deve começar com '!'", label, action)
+			}
+			if j, ok := seen[action]; ok {
+				problem("%s: action %q repetida (já está em commands[%d])", label, action, j)
+			}
+			seen[action] = i
+		}
+		if command.Help == "" {
+			problem("%s: sem help", label)
+		}
+		if command.Ajuda == "" {
+			problem("%s: sem ajuda", label)
+		}
+		if command.Permission != "" {
+			if _, ok := levels[command.Permission]; !ok {
+				if _, ok := c.AllowLists[command.Permission]; !ok {
+					problem("%s: permission %q não é um nível nem uma allow-list", label, command.Permission)
+				}
+			}
+		}
+		if strings.ContainsAny(command.Counter, " :*!") {
+			problem("%s: counter %q não pode ter espaços, ':', '*' ou '!'", label, command.Counter)
+		}
+		if r := command.Reply; r != "" && r != CooldownWhisper && r != CooldownSilent {
+			problem("%s: cooldown-reply %q deve ser %q ou %q", label, r, CooldownWhisper, CooldownSilent)
+		}
+		for _, response := range command.Responses {
+			checkTemplate(label, response)
+		}
+		for _, l := range command.Logs {
+			checkTemplate(label+" (logs)", l)
+		}
+		checkTemplate(label+" (negado)", command.Negado)
+		checkTemplate(label+" (denied)", command.Denied)
+	}
+	for _, ignored := range c.IgnoredCommands {
+		if _, ok := seen[ignored]; ok {
+			problem("ignored-commands: %q também é um comando", ignored)
+		}
+	}
+	if c.Language != "" && c.Language != LanguagePtBr && c.Language != LanguageEn {
+		problem("language %q deve ser %q ou %q", c.Language, LanguagePtBr, LanguageEn)
+	}
+	for i, timer := range c.Timers {
+		label := fmt.Sprintf("timers[%d] %s", i, timer.Name)
+		if timer.Interval <= 0 {
+			problem("%s: sem interval", label)
+		}
+		if len(timer.Responses) == 0 {
+			problem("%s: sem responses", label)
+		}
+		for _, response := range timer.Responses {
+			checkTemplate(label, response)
+		}
+	}
+	if c.Greetings.ReturningDays < 0 {
+		problem("greetings: returning-days não pode ser negativo")
+	}
+	for _, kind := range []string{GreetingFirst, GreetingReturning, GreetingRaid} {
+		for _, response := range c.GreetingResponses(kind) {
+			checkTemplate("greetings "+kind, response)
+		}
+	}
+	if c.SongRequests.MaxPerUser < 0 {
+		problem("song-requests: max-per-user não pode ser negativo")
+	}
+	if c.SongRequests.MaxDuration < 0 || c.SongRequests.Lead < 0 {
+		problem("song-requests: max-duration e lead não podem ser negativos")
+	}
+	if c.SkipPoll.Window < 0 {
+		problem("skip-poll: window não pode ser negativo")
+	}
+	if c.SkipPoll.Ratio < 0 || c.SkipPoll.Ratio > 1 {
+		problem("skip-poll: ratio deve estar entre 0 e 1")
+	}
+	if c.SkipPoll.MinVotes < 0 {
+		problem("skip-poll: min-votes não pode ser negativo")
+	}
+	rewardIDs := make(map[string]bool)
+	for i, reward := range c.Rewards {
+		label := fmt.Sprintf("rewards[%d] %s", i, reward.Name)
+		if reward.ID == "" {
+			problem("%s: sem id", label)
+		} else if rewardIDs[reward.ID] {
+			problem("%s: id %q repetido", label, reward.ID)
+		}
+		rewardIDs[reward.ID] = true
+		switch reward.Action {
+		case RewardPublish:
+			if t := reward.Topic; t != "" && t != events.TopicRewardRedeemed && t != events.TopicTTSRequested {
+				problem("%s: topic %q deve ser %q ou %q", label, t, events.TopicRewardRedeemed, events.TopicTTSRequested)
+			}
+		case RewardTemplate:
+			if len(reward.Responses) == 0 {
+				problem("%s: sem responses", label)
+			}
+			for _, response := range reward.Responses {
+				checkTemplate(label, response)
+			}
+		case RewardSong:
+		case RewardOverlay:
+			if reward.Effect == "" {
+				problem("%s: sem effect", label)
+			}
+		default:
+			problem("%s: action %q deve ser %q, %q, %q ou %q", label, reward.Action,
+				RewardPublish, RewardTemplate, RewardSong, RewardOverlay)
+		}
+	}
+	for i, rule := range c.Moderation {
+		label := fmt.Sprintf("moderation[%d] %s", i, rule.Name)
+		if rule.Name == "" {
+			problem("%s: sem name", label)
+		}
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				problem("%s: pattern %q: %v", label, rule.Pattern, err)
+			}
+		}
+		switch rule.Kind {
+		case RuleRegex:
+			if rule.Pattern == "" {
+				problem("%s: sem pattern", label)
+			}
+		case RuleLinks:
+		case RuleCaps:
+			if rule.Ratio <= 0 || rule.Ratio > 1 {
+				problem("%s: ratio deve estar entre 0 e 1", label)
+			}
+		case RuleRepeat, RuleEmotes:
+			if rule.Max <= 0 {
+				problem("%s: sem max", label)
+			}
+		case RuleFollowName:
+			if rule.Pattern == "" && len(rule.Prefixes) == 0 && len(rule.Suffixes) == 0 {
+				problem("%s: sem pattern, prefixes ou suffixes", label)
+			}
+		default:
+			problem("%s: kind %q deve ser %q, %q, %q, %q, %q ou %q", label, rule.Kind,
+				RuleRegex, RuleLinks, RuleCaps, RuleRepeat, RuleEmotes, RuleFollowName)
+		}
+		switch rule.Action {
+		case ModerationTimeout, ModerationBan, ModerationDelete, ModerationWarn:
+		default:
+			problem("%s: action %q deve ser %q, %q, %q ou %q", label, rule.Action,
+				ModerationTimeout, ModerationBan, ModerationDelete, ModerationWarn)
+		}
+		if rule.Duration < 0 {
+			problem("%s: duration não pode ser negativa", label)
+		}
+		if rule.Action == ModerationWarn && rule.Reason == "" {
+			problem("%s: warn sem reason", label)
+		}
+		if rule.Exempt != "" {
+			if _, ok := levels[rule.Exempt]; !ok {
+				if _, ok := c.AllowLists[rule.Exempt]; !ok {
+					problem("%s: exempt %q não é um nível nem uma allow-list", label, rule.Exempt)
+				}
+			}
+		}
+	}
+	if l := c.Lockdown; l.MinAccounts < 0 || l.MinAffix < 0 || l.MaxDistance < 0 || l.Window < 0 || l.Cooldown < 0 {
+		problem("lockdown: valores não podem ser negativos")
+	}
+	if c.Lockdown.MinAccounts == 1 {
+		problem("lockdown: min-accounts 1 trancaria o chat a cada seguidor novo")
+	}
+	for _, mode := range c.Lockdown.Modes {
+		if _, ok := lockdownModes[mode]; !ok {
+			problem("lockdown: mode %q deve ser %q, %q ou %q", mode, LockdownFollowers, LockdownEmoteOnly, LockdownSlow)
+		}
+	}
+
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// CheckTemplate parses text with funcs and makes sure every field it uses
+// (e.g. {{ .Sender.DisplayName }}) exists in the type of vars.
+func CheckTemplate(text string, funcs template.FuncMap, vars interface{}) error {
+	tmpl, err := template.New("check").Funcs(funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return checkNode(tmpl.Tree.Root, reflect.TypeOf(vars))
+}
+
+// checkNode walks the template tree. dot is nil where its type is unknown
+// (inside range and with).
+func checkNode(node parse.Node, dot reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNode(child, dot); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkNode(n.Pipe, dot)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, command := range n.Cmds {
+			for _, arg := range command.Args {
+				if err := checkNode(arg, dot); err != nil {
+					return err
+				}
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode, dot, dot)
+	case *parse.RangeNode:
+		return checkBranch(&n.BranchNode, dot, nil)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode, dot, nil)
+	case *parse.FieldNode:
+		return checkFields(dot, n.Ident)
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode, dot, inner reflect.Type) error {
+	if err := checkNode(n.Pipe, dot); err != nil {
+		return err
+	}
+	if err := checkNode(n.List, inner); err != nil {
+		return err
+	}
+	return checkNode(n.ElseList, dot)
+}
+
+func checkFields(typ reflect.Type, idents []string) error {
+	for _, ident := range idents {
+		if typ == nil || typ.Kind() == reflect.Interface {
+			return nil
+		}
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		// the method set of *T has the methods of T as well
+		if method, ok := reflect.PtrTo(typ).MethodByName(ident); ok {
+			if method.Type.NumOut() == 0 {
+				return fmt.Errorf("método %q de %s não retorna nada", ident, typ)
+			}
+			typ = method.Type.Out(0)
+			continue
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := typ.FieldByName(ident)
+			if !ok {
+				return fmt.Errorf("campo %q não existe em %s", ident, typ)
+			}
+			typ = field.Type
+		case reflect.Map:
+			typ = typ.Elem()
+		default:
+			return fmt.Errorf("campo %q não existe em %s", ident, typ)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+type testVars struct {
+	Sender  *chat.User
+	CmdLine string
+	Extras  []string
+	Command commands.Commands
+	Roster  map[string]bool
+}
+
+func checkTemplate(text string) error {
+	return commands.CheckTemplate(text, template.FuncMap{
+		"random": func(choices []string) string { return choices[0] },
+	}, testVars{})
+}
+
+func TestValidate(t *testing.T) {
+	var tt = []struct {
+		name     string
+		json     string
+		expected []string
+	}{
+		{"ok", `{"commands": [{"help": "h", "ajuda": "a", "actions": ["!gh"], "responses": [
+			"/me {{ .Sender.DisplayName }} {{ random .Extras }} {{ .Command.Ajuda .CmdLine }} {{ len .Roster }}",
+			"{{ range .Command.Urls .CmdLine }}/me {{ .Whatever }}{{ end }}"
+		]}]}`, nil},
+		{"missing help", `{"commands": [{"actions": ["!gh"]}]}`,
+			[]string{"!gh: sem help", "!gh: sem ajuda"}},
+		{"action without !", `{"commands": [{"help": "h", "ajuda": "a", "actions": ["gh"]}]}`,
+			[]string{`gh: action "gh" deve começar com '!'`}},
+		{"duplicated action", `{"commands": [
+			{"help": "h", "ajuda": "a", "actions": ["!gh"]},
+			{"help": "h", "ajuda": "a", "actions": ["!github", "!gh"]}]}`,
+			[]string{`!github: action "!gh" repetida (já está em commands[0])`}},
+		{"broken template", `{"commands": [{"help": "h", "ajuda": "a", "actions": ["!gh"], "responses": ["{{ .CmdLine "]}]}`,
+			[]string{`!gh: template "{{ .CmdLine ": template: check:1: unclosed action`}},
+		{"unknown field", `{"commands": [{"help": "h", "ajuda": "a", "actions": ["!gh"], "logs": ["{{ .Sender.Nome }}"]}]}`,
+			[]string{`!gh (logs): template "{{ .Sender.Nome }}": campo "Nome" não existe em chat.User`}},
+		{"unknown function", `{"commands": [{"help": "h", "ajuda": "a", "actions": ["!gh"], "responses": ["{{ shuffle .Extras }}"]}]}`,
+			[]string{`!gh: template "{{ shuffle .Extras }}": template: check:1: function "shuffle" not defined`}},
+		{"unknown permission", `{"commands": [{"help": "h", "ajuda": "a", "actions": ["!gh"], "permission": "amigos"}]}`,
+			[]string{`!gh: permission "amigos" não é um nível nem uma allow-list`}},
+		{"broken timer", `{"timers": [{"name": "hidrata", "responses": ["{{ .Player.CurrentSong }}"]}]}`,
+			[]string{"timers[0] hidrata: sem interval",
+				`timers[0] hidrata: template "{{ .Player.CurrentSong }}": campo "Player" não existe em commands_test.testVars`}},
+		{"broken rewards", `{"rewards": [
+			{"name": "tts", "id": "1", "action": "publish", "topic": "marquee_updated"},
+			{"name": "sr", "id": "1", "action": "song"},
+			{"name": "oi", "id": "2", "action": "template"},
+			{"name": "confete", "id": "3", "action": "overlay"},
+			{"name": "nada", "action": "dance"}]}`,
+			[]string{`rewards[0] tts: topic "marquee_updated" deve ser "reward_redeemed" ou "create_tts"`,
+				`rewards[1] sr: id "1" repetido`,
+				"rewards[2] oi: sem responses",
+				"rewards[3] confete: sem effect",
+				"rewards[4] nada: sem id",
+				`rewards[4] nada: action "dance" deve ser "publish", "template", "song" ou "overlay"`}},
+		{"broken moderation", `{"moderation": [
+			{"name": "spam", "kind": "regex", "pattern": "(big", "action": "ban"},
+			{"name": "caps", "kind": "caps", "action": "warn", "exempt": "amigos"},
+			{"name": "bots", "kind": "follow-name", "action": "kick"}]}`,
+			[]string{"moderation[0] spam: pattern \"(big\": error parsing regexp: missing closing ): `(big`",
+				"moderation[1] caps: ratio deve estar entre 0 e 1",
+				"moderation[1] caps: warn sem reason",
+				`moderation[1] caps: exempt "amigos" não é um nível nem uma allow-list`,
+				"moderation[2] bots: sem pattern, prefixes ou suffixes",
+				`moderation[2] bots: action "kick" deve ser "timeout", "ban", "delete" ou "warn"`}},
+		{"broken lockdown", `{"lockdown": {"min-accounts": 1, "max-distance": -1, "modes": ["slow", "subs-only"]}}`,
+			[]string{"lockdown: valores não podem ser negativos",
+				"lockdown: min-accounts 1 trancaria o chat a cada seguidor novo",
+				`lockdown: mode "subs-only" deve ser "followers", "emote-only" ou "slow"`}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c commands.Commands
+			assert.NoError(t, c.Load(strings.NewReader(tc.json)))
+			err := c.Validate(checkTemplate)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, commands.ValidationError{}, err) {
+				assert.Equal(t, tc.expected, err.(commands.ValidationError).Problems)
+			}
+		})
+	}
+}
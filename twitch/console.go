@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat/console"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/outbox"
+	"github.com/sirupsen/logrus"
+)
+
+// consolePublisher prints the events instead of sending them to RabbitMQ.
+type consolePublisher struct {
+	out io.Writer
+}
+
+func (p consolePublisher) Publish(topic string, body []byte, _ time.Duration) error {
+	if topic == events.TopicChatMessage {
+		return nil // só alimenta as estatísticas
+	}
+	payload := body
+	if env, err := events.Decode(body); err == nil {
+		payload = env.Payload
+	}
+	_, err := fmt.Fprintf(p.out, "amqp> %s %s\n", topic, payload)
+	return err
+}
+
+// consoleStatus stands in for helix, so the console doesn't need the API.
+type consoleStatus struct {
+	live bool
+}
+
+func (s consoleStatus) IsLive() (bool, error) { return s.live, nil }
+
+func (consoleStatus) ChannelInformation() (game, title string, err error) {
+	return "Software and Game Development", "live de mentirinha no console", nil
+}
+
+// runConsole lets you chat with the bot from the terminal, using the same
+// dispatch as the live channel but an in-memory redis and no RabbitMQ:
+//
+//	go run . console -user acaverna -badges moderator,subscriber [-live]
+//
+// Type "/as <user> [badge,...]" to switch users.
+func runConsole(args []string) {
+	flags := flag.NewFlagSet("console", flag.ExitOnError)
+	userName := flags.String("user", "moniquelive", "quem está digitando")
+	badges := flags.String("badges", "broadcaster", "badges separadas por vírgula")
+	live := flags.Bool("live", false, "finge que a live está no ar (timers só de live)")
+	_ = flags.Parse(args)
+
+	logrus.SetLevel(logrus.WarnLevel)
+	fake, err := miniredis.Run()
+	if err != nil {
+		log.Fatalln("miniredis:", err)
+	}
+	defer fake.Close()
+	red = redis.NewClient(&redis.Options{Addr: fake.Addr()})
+	commands.SetRedis(red)
+	commands.SetArchive(archiveURL)
+	commands.SetStreamStatus(consoleStatus{live: *live})
+	if err := cmd.Reload(checkTemplate); err != nil {
+		log.Fatalln(err)
+	}
+
+	publisher := consolePublisher{out: os.Stdout}
+	commands.SetPublisher(publisher)
+
+	platform := console.New(os.Stdin, os.Stdout, console.NewUser(*userName, *badges))
+	client, err := NewTwitch(platform, &cmd, publisher)
+	if err != nil {
+		log.Fatalln("NewTwitch(): ", err)
+	}
+	client.outbox.SetLimit(outbox.Unlimited)
+	go NewWatcher(client.Reload)
+
+	fmt.Println(`digite mensagens do chat ("/as <user> [badges]" troca de usuário, ctrl+d sai)`)
+	if err := client.Connect(); err != nil {
+		log.Errorln("client.Connect(): ", err)
+	}
+	client.Shutdown()
+}
@@ -3,19 +3,23 @@ module github.com/moniquelive/moniquelive-bot/twitch
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.3
 	github.com/elazarl/goproxy v0.0.0-20211114080932-d06c3be7c11b // indirect
 	github.com/fsnotify/fsnotify v1.4.9
 	github.com/gempir/go-twitch-irc/v2 v2.5.0
 	github.com/go-redis/redis v6.15.9+incompatible
-	github.com/google/uuid v1.3.0
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/moniquelive/moniquelive-bot/shared v0.0.0-00010101000000-000000000000
 	github.com/nicklaw5/helix v1.25.0
 	github.com/onsi/gomega v1.14.0 // indirect
 	github.com/parnurzeal/gorequest v0.2.16
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sirupsen/logrus v1.8.1
 	github.com/smartystreets/goconvey v1.7.2 // indirect
-	github.com/streadway/amqp v1.0.0
+	github.com/streadway/amqp v1.0.0 // indirect
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
 	moul.io/http2curl v1.0.0 // indirect
 )
+
+replace github.com/moniquelive/moniquelive-bot/shared => ../shared
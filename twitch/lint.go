@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+)
+
+// runLint validates commands.json files (the default one if none is given)
+// and returns the exit code, so it can run as a pre-commit hook:
+//
+//	go run . lint config/commands.json
+func runLint(paths []string) int {
+	if len(paths) == 0 {
+		paths = []string{commands.ConfigPath}
+	}
+	code := 0
+	for _, path := range paths {
+		if _, err := commands.Open(path, checkTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s: ok\n", path)
+	}
+	return code
+}
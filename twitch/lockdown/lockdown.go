@@ -0,0 +1,261 @@
+// Package lockdown spots waves of follow bots (look-alike accounts following
+// or chatting for the first time in a short while) and keeps the chat locked
+// while they last.
+package lockdown
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// TickInterval is how often Run checks whether the lockdown is over.
+	TickInterval = 10 * time.Second
+	// RaidGrace is how long after a raid first-time chatters aren't counted:
+	// raiders chatting for the first time often have look-alike names.
+	RaidGrace = 5 * time.Minute
+)
+
+var log = logrus.WithField("package", "lockdown")
+
+// Config is what makes a wave.
+type Config struct {
+	Window      time.Duration // how far back look-alikes count
+	MinAccounts int           // look-alikes in the window that make a wave, 0 turns the detector off
+	MinAffix    int           // shared prefix or suffix that makes two names alike
+	MaxDistance int           // edit distance that makes two names alike
+	Cooldown    time.Duration // quiet time (no look-alikes) before the lockdown ends
+}
+
+// Wave is a batch of look-alike accounts.
+type Wave struct {
+	Accounts []string
+	Started  bool // the lockdown starts with it (otherwise it's a straggler)
+}
+
+type arrival struct {
+	name string
+	at   time.Time
+}
+
+// Detector watches the accounts arriving at the chat.
+type Detector struct {
+	clock    clock.Clock
+	onWave   func(Wave)
+	onUnlock func()
+
+	mu     sync.Mutex
+	cfg    Config
+	recent []arrival
+	locked bool
+	until  time.Time
+	caught map[string]bool
+	raided time.Time
+}
+
+// New returns a detector that is off until configured. onWave is called with
+// the accounts of every wave (and the stragglers while locked) and onUnlock
+// when the lockdown ends.
+func New(clock clock.Clock, onWave func(Wave), onUnlock func()) *Detector {
+	return &Detector{clock: clock, onWave: onWave, onUnlock: onUnlock}
+}
+
+// Configure replaces the config, keeping the lockdown if there's one.
+func (d *Detector) Configure(cfg Config) {
+	d.mu.Lock()
+	d.cfg = cfg
+	d.mu.Unlock()
+}
+
+// Arrived is called for every new follower (and, through Chatted, first-time
+// chatter), telling if name was caught in a wave.
+func (d *Detector) Arrived(name string) bool {
+	name = strings.ToLower(name)
+	now := d.clock.Now()
+	d.mu.Lock()
+	if d.cfg.MinAccounts <= 0 || d.caught[name] {
+		caught := d.caught[name]
+		d.mu.Unlock()
+		return caught
+	}
+	var recent []arrival
+	for _, a := range d.recent {
+		if now.Sub(a.at) < d.cfg.Window && a.name != name {
+			recent = append(recent, a)
+		}
+	}
+	d.recent = append(recent, arrival{name: name, at: now})
+
+	var wave Wave
+	if d.locked && d.alikeCaught(name) {
+		wave.Accounts = []string{name}
+	} else if alike := d.alike(name); len(alike) >= d.cfg.MinAccounts {
+		wave.Accounts = alike
+		wave.Started = !d.locked
+	}
+	if len(wave.Accounts) == 0 {
+		d.mu.Unlock()
+		return false
+	}
+	if wave.Started {
+		d.locked = true
+		d.caught = make(map[string]bool)
+	}
+	for _, account := range wave.Accounts {
+		d.caught[account] = true
+	}
+	d.until = now.Add(d.cfg.Cooldown)
+	until := d.until
+	d.mu.Unlock()
+
+	log.Warnf("onda de bots: %v (lockdown até %v)", wave.Accounts, until.Format("15:04:05"))
+	d.onWave(wave)
+	return true
+}
+
+// Raided is called when someone raids the channel.
+func (d *Detector) Raided() {
+	now := d.clock.Now()
+	d.mu.Lock()
+	d.raided = now
+	d.mu.Unlock()
+}
+
+// Chatted is called for every first-time chatter. It's Arrived, except right
+// after a raid, when only accounts already caught are told apart.
+func (d *Detector) Chatted(name string) bool {
+	now := d.clock.Now()
+	d.mu.Lock()
+	raiding := !d.raided.IsZero() && now.Sub(d.raided) < RaidGrace
+	caught := d.caught[strings.ToLower(name)]
+	d.mu.Unlock()
+	if raiding {
+		return caught
+	}
+	return d.Arrived(name)
+}
+
+// Locked tells whether there's a lockdown going on.
+func (d *Detector) Locked() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.locked
+}
+
+// Unlock ends the lockdown now, telling if there was one.
+func (d *Detector) Unlock() bool {
+	d.mu.Lock()
+	if !d.locked {
+		d.mu.Unlock()
+		return false
+	}
+	d.locked, d.caught, d.recent = false, nil, nil
+	d.mu.Unlock()
+
+	log.Infoln("lockdown encerrado")
+	d.onUnlock()
+	return true
+}
+
+// Tick ends the lockdown once the cooldown has passed without look-alikes.
+func (d *Detector) Tick() {
+	d.mu.Lock()
+	due := d.locked && !d.clock.Now().Before(d.until)
+	d.mu.Unlock()
+	if due {
+		d.Unlock()
+	}
+}
+
+// Run calls Tick every TickInterval until stop is closed.
+func (d *Detector) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-d.clock.After(TickInterval):
+			d.Tick()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// alike returns the recent names (name included, in order of arrival) alike
+// to name that weren't caught yet. Must be called with mu held.
+func (d *Detector) alike(name string) (names []string) {
+	for _, a := range d.recent {
+		if !d.caught[a.name] && Alike(a.name, name, d.cfg.MinAffix, d.cfg.MaxDistance) {
+			names = append(names, a.name)
+		}
+	}
+	return
+}
+
+// alikeCaught tells whether name looks like one of the accounts caught in
+// this lockdown. Must be called with mu held.
+func (d *Detector) alikeCaught(name string) bool {
+	for caught := range d.caught {
+		if Alike(caught, name, d.cfg.MinAffix, d.cfg.MaxDistance) {
+			return true
+		}
+	}
+	return false
+}
+
+// Alike tells whether names a and b share a prefix or a suffix of at least
+// minAffix characters or are at most maxDistance edits apart. Names shorter
+// than minAffix are only alike to themselves.
+func Alike(a, b string, minAffix, maxDistance int) bool {
+	x, y := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	if string(x) == string(y) {
+		return true
+	}
+	if len(x) < minAffix || len(y) < minAffix {
+		return false
+	}
+	prefix, suffix := 0, 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	if minAffix > 0 && (prefix >= minAffix || suffix >= minAffix) {
+		return true
+	}
+	return maxDistance > 0 && distance(x, y) <= maxDistance
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
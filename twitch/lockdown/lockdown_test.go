@@ -0,0 +1,146 @@
+package lockdown_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/lockdown"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReplay feeds the follows and first-time chatters of each
+// testdata/*.txt to a detector and compares what it did with the want lines:
+//
+//	config window=1m min-accounts=4 min-affix=6 max-distance=2 cooldown=5m
+//	at <offset> follow|chat|raid <name>
+//	tick <offset>
+//	unlock
+//	want lock|ban <names...> | want unlock
+func TestReplay(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/*.txt")
+	if err != nil || len(scripts) == 0 {
+		t.Fatal("nenhum script em testdata:", err)
+	}
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			got, want := replay(t, script)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func replay(t *testing.T, script string) (got, want []string) {
+	file, err := os.Open(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	start := time.Date(2021, 7, 1, 20, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	detector := lockdown.New(clk, func(wave lockdown.Wave) {
+		action := "ban"
+		if wave.Started {
+			action = "lock"
+		}
+		got = append(got, action+" "+strings.Join(wave.Accounts, " "))
+	}, func() {
+		got = append(got, "unlock")
+	})
+	at := func(offset string) {
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			t.Fatalf("%v: %v", script, err)
+		}
+		clk.Set(start.Add(d))
+	}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch {
+		case fields[0] == "config":
+			detector.Configure(parseConfig(t, fields[1:]))
+		case fields[0] == "at" && len(fields) == 4:
+			at(fields[1])
+			switch fields[2] {
+			case "follow":
+				detector.Arrived(fields[3])
+			case "chat":
+				detector.Chatted(fields[3])
+			case "raid":
+				detector.Raided()
+			default:
+				t.Fatalf("%v:%d: chegada desconhecida %q", script, line, fields[2])
+			}
+		case fields[0] == "tick" && len(fields) == 2:
+			at(fields[1])
+			detector.Tick()
+		case fields[0] == "unlock":
+			detector.Unlock()
+		case fields[0] == "want":
+			want = append(want, strings.Join(fields[1:], " "))
+		default:
+			t.Fatalf("%v:%d: linha desconhecida %q", script, line, scanner.Text())
+		}
+	}
+	return
+}
+
+func parseConfig(t *testing.T, settings []string) (cfg lockdown.Config) {
+	for _, setting := range settings {
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			t.Fatalf("config %q", setting)
+		}
+		var err error
+		switch kv[0] {
+		case "window":
+			cfg.Window, err = time.ParseDuration(kv[1])
+		case "cooldown":
+			cfg.Cooldown, err = time.ParseDuration(kv[1])
+		case "min-accounts":
+			cfg.MinAccounts, err = strconv.Atoi(kv[1])
+		case "min-affix":
+			cfg.MinAffix, err = strconv.Atoi(kv[1])
+		case "max-distance":
+			cfg.MaxDistance, err = strconv.Atoi(kv[1])
+		default:
+			err = fmt.Errorf("desconhecido")
+		}
+		if err != nil {
+			t.Fatalf("config %q: %v", setting, err)
+		}
+	}
+	return
+}
+
+func TestAlike(t *testing.T) {
+	var tt = []struct {
+		a, b     string
+		expected bool
+	}{
+		{"hoss00312_aa1", "HOSS00312_bb2", true},
+		{"aa1_hoss00312", "bb2_hoss00312", true},
+		{"zorqela", "zurqela", true},
+		{"zorqela", "zurqelo", true},
+		{"zorqela", "marcos", false},
+		{"ana", "bia", false},
+		{"ana", "ANA", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			assert.Equal(t, tc.expected, lockdown.Alike(tc.a, tc.b, 6, 2))
+		})
+	}
+}
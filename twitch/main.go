@@ -2,17 +2,21 @@ package main
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/shared/mq"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat/twitchirc"
 	"github.com/moniquelive/moniquelive-bot/twitch/commands"
 
-	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"github.com/streadway/amqp"
 )
 
 //go:embed .oauth
@@ -21,18 +25,18 @@ var oauth string
 var cmd commands.Commands
 
 const (
-	username           = "moniquelive_bot"
-	queueName          = "ms.twitch"
-	createTtsTopicName = "create_tts"
-	spotifyTopicName   = "spotify_music_updated"
-	musicSkipPollName  = "twitch-bot:twitch:poll:skip_music"
-	musicKeepPollName  = "twitch-bot:twitch:poll:keep_music"
+	username       = "moniquelive_bot"
+	producerName   = "twitch"
+	queueName      = "ms.twitch"
+	songQueueName  = "ms.twitch.song_requests"
+	songMaxRetries = 3
 )
 
 var (
-	amqpURL  = os.Getenv("RABBITMQ_URL")
-	redisURL = os.Getenv("REDIS_URL")
-	log      = logrus.WithField("package", "main")
+	amqpURL    = os.Getenv("RABBITMQ_URL")
+	redisURL   = os.Getenv("REDIS_URL")
+	archiveURL = os.Getenv("ARCHIVE_URL")
+	log        = logrus.WithField("package", "main")
 )
 
 func init() {
@@ -42,7 +46,6 @@ func init() {
 		TimestampFormat: time.StampMilli,
 	})
 	logrus.SetLevel(logrus.TraceLevel) // sets log level
-	cmd.Reload()
 }
 
 func check(err error) {
@@ -52,128 +55,127 @@ func check(err error) {
 }
 
 func main() {
-	defer log.Debugln("AMQP consumer shutdown.")
-
-	go NewWatcher()
-
-	var err error
-
-	conn, err := amqp.Dial(amqpURL)
-	check(err)
-	defer conn.Close()
-	go func() { log.Debugf("closing: %s", <-conn.NotifyClose(make(chan *amqp.Error))) }()
-
-	log.Debugln("got Connection, getting Channel")
-	channel, err := conn.Channel()
-	check(err)
-	defer channel.Close()
-
-	log.Debugf("declaring Queue %q", queueName)
-	queue, err := channel.QueueDeclare(
-		queueName, // name of the queue
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // noWait
-		nil,       // arguments
-	)
-	check(err)
-
-	log.Debugf("binding Queue %q to amq.topic", queueName)
-	err = channel.QueueBind(queueName, spotifyTopicName, "amq.topic", false, nil)
-	check(err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "console":
+			runConsole(os.Args[2:])
+			return
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "quotes":
+			os.Exit(runQuotes(os.Args[2:]))
+		}
+	}
 
-	log.Debugln("Setting QoS")
-	err = channel.Qos(1, 0, true)
-	check(err)
+	defer log.Debugln("AMQP consumer shutdown.")
+	red = redis.NewClient(&redis.Options{Addr: redisURL})
+	if _, err := red.Ping().Result(); err != nil {
+		log.Fatalln("sem redis:", err)
+	}
+	commands.SetRedis(red) // before loading, or the commands created from chat are left out
+	commands.SetArchive(archiveURL)
+	if err := cmd.Reload(checkTemplate); err != nil {
+		log.Fatalln(err)
+	}
 
-	log.Debugf("declared Queue (%q %d messages, %d consumers)", queue.Name, queue.Messages, queue.Consumers)
-
-	tag := uuid.NewString()
-	log.Debugf("starting Consume (tag:%q)", tag)
-	deliveries, err := channel.Consume(
-		queue.Name, // name
-		tag,        // consumerTag,
-		false,      // noAck
-		false,      // exclusive
-		false,      // noLocal
-		false,      // noWait
-		nil,        // arguments
-	)
-	check(err)
+	mqClient := mq.Dial(amqpURL)
+	defer mqClient.Close()
+	commands.SetPublisher(mqClient)
 
-	client, err := NewTwitch(username, oauth, &cmd, channel)
+	client, err := NewTwitch(twitchirc.New(username, oauth, channel), &cmd, mqClient)
 	if err != nil {
 		log.Panicln("NewTwitch(): ", err)
 	}
+	go NewWatcher(client.Reload)
+	go pollStream()
+
+	err = mqClient.Consume(mq.Topology{
+		Queue:  queueName,
+		Topics: []string{events.TopicSongUpdated},
+	}, func(d *mq.Delivery) error {
+		return handle(d, client)
+	})
+	check(err)
 
-	go handle(deliveries, client)
+	err = mqClient.Consume(mq.Topology{
+		Queue:      songQueueName,
+		Topics:     []string{events.TopicSongRequested},
+		MaxRetries: songMaxRetries,
+		RetryDelay: 10 * time.Second,
+	}, func(d *mq.Delivery) error {
+		return handleSongRequest(d, client)
+	})
+	check(err)
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		client.Shutdown()
+	}()
 
 	err = client.Connect()
 	if err != nil {
 		log.Panicln("client.Connect(): ", err)
 	}
-
-	//// wait for interrupt signal
-	//stopChan := make(chan os.Signal, 1)
-	//signal.Notify(stopChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	//for {
-	//	select {
-	//	case <-stopChan:
-	//		// will close the deliveries channel
-	//		err = channel.Cancel(tag, true)
-	//		check(err)
-	//
-	//	// wait for go handle(...)
-	//	case <-deliveriesDoneChan:
-	//		return
-	//	}
-	//}
 }
 
-func handle(deliveries <-chan amqp.Delivery, client *Twitch) {
-	defer log.Println("AMQP Handler: Exiting from deliveries handler")
-
-	log.Debugln("Listening...")
-	for delivery := range deliveries {
-		if delivery.Body == nil {
-			return
-		}
-		_ = delivery.Ack(false)
-
-		if len(delivery.Body) == 0 {
-			log.Debugln("empty message. ignoring...")
-			continue
+// pollStream keeps the stream sessions of twitch_stats in sync with helix.
+func pollStream() {
+	for {
+		if err := commands.PollStream(); err != nil {
+			log.Errorln("pollStream:", err)
 		}
-		log.Infoln("DELIVERY:", string(delivery.Body))
+		time.Sleep(commands.StreamPollInterval)
+	}
+}
 
-		var songInfo songInfo
-		err := parseSongInfo(delivery.Body, &songInfo)
-		if err != nil {
-			log.Errorln("handle > json.Unmarshal:", err)
-			continue
-		}
-		client.Say("/color Chocolate")
-		client.Say(fmt.Sprintf("/me %v - %v - %v (%v)",
-			songInfo.Artist, songInfo.Title,
-			strings.ReplaceAll(songInfo.SongUrl, "https://open.spotify.com/track/", "https://song.link/s/"),
-			commands.FormatDuration(time.Duration(songInfo.Length)*time.Second)))
+func handle(delivery *mq.Delivery, client *Twitch) error {
+	if len(delivery.Body) == 0 {
+		log.Debugln("empty message. ignoring...")
+		return nil
+	}
+	log.Infoln("DELIVERY:", string(delivery.Body))
 
-		createPoll(songInfo.Length)
+	env, err := events.Decode(delivery.Body)
+	if err != nil {
+		return mq.Permanent(err)
 	}
+	var songInfo events.SongUpdated
+	if err := env.Unmarshal(&songInfo); err != nil {
+		return mq.Permanent(err)
+	}
+	client.Say("/color Chocolate")
+	client.Say(fmt.Sprintf("/me %v - %v - %v (%v)",
+		songInfo.Artist, songInfo.Title,
+		strings.ReplaceAll(songInfo.SongUrl, "https://open.spotify.com/track/", "https://song.link/s/"),
+		commands.FormatDuration(time.Duration(songInfo.Length)*time.Second)))
+
+	client.SongStarted(time.Duration(songInfo.Length) * time.Second)
+	return nil
 }
 
-func createPoll(ttl int64) {
-	r := redis.NewClient(&redis.Options{Addr: redisURL})
-	if r.Ping().Err() != nil {
-		log.Println("Twitch.createPoll > Sem redis...")
-		return
+func handleSongRequest(delivery *mq.Delivery, client *Twitch) error {
+	log.Infoln("SONG REQUEST:", string(delivery.Body))
+	env, err := events.Decode(delivery.Body)
+	if err != nil {
+		return mq.Permanent(err)
 	}
-	defer r.Close()
-
-	for _, setName := range []string{musicSkipPollName, musicKeepPollName} {
-		r.Del(setName)
-		r.SAdd(setName, ".")
-		r.Expire(setName, time.Duration(ttl)*time.Second)
+	var request events.SongRequested
+	if err := env.Unmarshal(&request); err != nil {
+		return mq.Permanent(err)
+	}
+	msg, err := cmd.RequestSong(request.User, request.URL)
+	if err != nil {
+		if errors.Is(err, commands.ErrInvalidSongURL) || errors.Is(err, commands.ErrSongRejected) {
+			client.Say(err.Error())
+			return mq.Permanent(err)
+		}
+		if delivery.Retries() >= songMaxRetries {
+			client.Say(err.Error())
+		}
+		return err
 	}
+	client.Say(msg)
+	return nil
 }
@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	logRedisKey = "twitch-bot:twitch:moderation:log" // list of Decision, most recent first
+	logSize     = 1000
+)
+
+// Log keeps the last decisions in redis.
+type Log struct {
+	red *redis.Client
+}
+
+func NewLog(red *redis.Client) *Log {
+	return &Log{red: red}
+}
+
+// Record stamps d and keeps it.
+func (l *Log) Record(d Decision) {
+	if d.At.IsZero() {
+		d.At = time.Now()
+	}
+	log.WithField("rule", d.Rule).Warnln("moderação:", d)
+	body, err := json.Marshal(d)
+	if err != nil {
+		log.Errorln("Record > Marshal:", err)
+		return
+	}
+	if err := l.red.LPush(logRedisKey, body).Err(); err != nil {
+		log.Errorln("Record > LPush:", err)
+		return
+	}
+	l.red.LTrim(logRedisKey, 0, logSize-1)
+}
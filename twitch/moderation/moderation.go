@@ -0,0 +1,216 @@
+// Package moderation checks chat messages and follower names against the
+// moderation rules of commands.json and tells what to do about them.
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/moniquelive/moniquelive-bot/shared/links"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("package", "moderation")
+
+// Decision is what to do about a message (or a follower) that broke a rule.
+type Decision struct {
+	Rule      string        `json:"rule"`
+	Action    string        `json:"action"`
+	User      string        `json:"user"`
+	MessageID string        `json:"messageId,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	Text      string        `json:"text"`
+	At        time.Time     `json:"at"`
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%v %v (regra %q): %q", d.Action, d.User, d.Rule, d.Text)
+}
+
+// Commands are the chat commands that carry the decision out.
+func (d Decision) Commands() []string {
+	switch d.Action {
+	case commands.ModerationTimeout:
+		return []string{strings.TrimSpace(fmt.Sprintf("/timeout %v %v %v", d.User, int(d.Duration.Seconds()), d.Reason))}
+	case commands.ModerationBan:
+		return []string{strings.TrimSpace(fmt.Sprintf("/ban %v %v", d.User, d.Reason))}
+	case commands.ModerationDelete:
+		var cmds []string
+		if d.MessageID != "" {
+			cmds = append(cmds, "/delete "+d.MessageID)
+		}
+		if d.Reason != "" {
+			cmds = append(cmds, fmt.Sprintf("/me @%v, %v", d.User, d.Reason))
+		}
+		return cmds
+	case commands.ModerationWarn:
+		return []string{strings.TrimSpace(fmt.Sprintf("/me @%v, %v", d.User, d.Reason))}
+	}
+	return nil
+}
+
+const defaultTimeout = 10 * time.Minute
+
+type rule struct {
+	commands.ModerationRule
+	rex *regexp.Regexp
+}
+
+// Engine holds the compiled rules.
+type Engine struct {
+	exempt    func(permission string, user *chat.User) bool
+	permitted func(user string) bool
+
+	mu    sync.RWMutex
+	rules []rule
+}
+
+// New returns an engine without rules. exempt tells whether user has the
+// exempt permission of a rule (see commands.HasPermission) and permitted
+// whether someone got a !permit to post links.
+func New(exempt func(permission string, user *chat.User) bool, permitted func(user string) bool) *Engine {
+	return &Engine{exempt: exempt, permitted: permitted}
+}
+
+// Load replaces the rules. Rules that don't compile are left out.
+func (e *Engine) Load(defs []commands.ModerationRule) {
+	var rules []rule
+	for _, def := range defs {
+		r := rule{ModerationRule: def}
+		if def.Pattern != "" {
+			rex, err := regexp.Compile(def.Pattern)
+			if err != nil {
+				log.Warnf("regra %q: pattern %q: %v, ignorando", def.Name, def.Pattern, err)
+				continue
+			}
+			r.rex = rex
+		}
+		if r.Exempt == "" {
+			r.Exempt = commands.PermissionModerator
+		}
+		rules = append(rules, r)
+	}
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+	log.Debugf("%d regras de moderação carregadas", len(rules))
+}
+
+// Check returns the decision of the first rule message breaks.
+func (e *Engine) Check(message chat.Message) (Decision, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, r := range e.rules {
+		if r.Kind == commands.RuleFollowName || e.exempt(r.Exempt, &message.User) {
+			continue
+		}
+		if r.Kind == commands.RuleLinks && e.permitted(message.User.Name) {
+			continue
+		}
+		if r.breaks(message) {
+			d := r.decision(message.User.Name, message.Text)
+			d.MessageID = message.ID
+			return d, true
+		}
+	}
+	return Decision{}, false
+}
+
+// CheckFollower returns the decision of the first follow-name rule name
+// matches.
+func (e *Engine) CheckFollower(name string) (Decision, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, r := range e.rules {
+		if r.Kind == commands.RuleFollowName && r.matchesName(name) {
+			return r.decision(name, "follow: "+name), true
+		}
+	}
+	return Decision{}, false
+}
+
+func (r rule) decision(user, text string) Decision {
+	d := Decision{Rule: r.Name, Action: r.Action, User: user, Reason: r.Reason, Text: text}
+	if d.Action == commands.ModerationTimeout {
+		d.Duration = time.Duration(r.Duration)
+		if d.Duration <= 0 {
+			d.Duration = defaultTimeout
+		}
+	}
+	return d
+}
+
+func (r rule) breaks(message chat.Message) bool {
+	switch r.Kind {
+	case commands.RuleRegex:
+		return r.rex != nil && r.rex.MatchString(message.Text)
+	case commands.RuleLinks:
+		return !links.AllAllowed(message.Text, r.Allow)
+	case commands.RuleCaps:
+		return shouting(message.Text, r.Ratio, r.MinLength)
+	case commands.RuleRepeat:
+		return longestRun(message.Text) > r.Max
+	case commands.RuleEmotes:
+		return message.Emotes > r.Max
+	}
+	return false
+}
+
+func (r rule) matchesName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range r.Prefixes {
+		if strings.HasPrefix(lower, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	for _, suffix := range r.Suffixes {
+		if strings.HasSuffix(lower, strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return r.rex != nil && r.rex.MatchString(name)
+}
+
+func shouting(text string, ratio float64, minLength int) bool {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters == 0 || letters < minLength {
+		return false
+	}
+	return float64(upper)/float64(letters) >= ratio
+}
+
+// longestRun is the longest sequence of the same character, spaces aside.
+func longestRun(text string) int {
+	longest, run := 0, 0
+	var last rune
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			run, last = 0, 0
+			continue
+		}
+		if r == last {
+			run++
+		} else {
+			run, last = 1, r
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
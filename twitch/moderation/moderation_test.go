@@ -0,0 +1,87 @@
+package moderation_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/moderation"
+	"github.com/stretchr/testify/assert"
+)
+
+var rules = []commands.ModerationRule{
+	{Name: "hoss", Kind: commands.RuleFollowName, Prefixes: []string{"hoss00312_"}, Suffixes: []string{"_hoss00312"},
+		Action: commands.ModerationBan, Reason: "follow bot"},
+	{Name: "bots", Kind: commands.RuleFollowName, Pattern: `^[a-z]+\d{6}$`, Action: commands.ModerationBan},
+	{Name: "spam", Kind: commands.RuleRegex, Pattern: `(?i)big ?follows`, Action: commands.ModerationBan, Reason: "spam"},
+	{Name: "links", Kind: commands.RuleLinks, Allow: []string{"github.com", "twitch.tv/*/clip"},
+		Action: commands.ModerationDelete, Reason: "links só com !permit"},
+	{Name: "caps", Kind: commands.RuleCaps, Ratio: 0.8, MinLength: 10, Action: commands.ModerationWarn,
+		Reason: "sem gritar", Exempt: commands.PermissionVIP},
+	{Name: "flood", Kind: commands.RuleRepeat, Max: 10, Action: commands.ModerationDelete},
+	{Name: "emotes", Kind: commands.RuleEmotes, Max: 5, Action: commands.ModerationTimeout,
+		Duration: commands.Duration(time.Minute), Reason: "emotes demais"},
+}
+
+func TestCheck(t *testing.T) {
+	var c commands.Commands
+	engine := moderation.New(c.HasPermission, func(user string) bool { return user == "amigo" })
+	engine.Load(rules)
+
+	viewer := chat.User{Name: "fulano"}
+	vip := chat.User{Name: "vip", Badges: map[string]int{"vip": 1}}
+	mod := chat.User{Name: "mod", Badges: map[string]int{"moderator": 1}}
+	friend := chat.User{Name: "amigo"}
+	var tt = []struct {
+		name     string
+		message  chat.Message
+		expected []string
+	}{
+		{"clean", chat.Message{User: viewer, Text: "oi, tudo bem? ola.tudo certo"}, nil},
+		{"spam", chat.Message{User: viewer, Text: "Get viewers on BIGFOLLOWS . com"}, []string{"/ban fulano spam"}},
+		{"mods are exempt", chat.Message{User: mod, Text: "bigfollows"}, nil},
+		{"link", chat.Message{ID: "42", User: viewer, Text: "olha isso: https://example.com/x"}, []string{"/delete 42", "/me @fulano, links só com !permit"}},
+		{"bare domain", chat.Message{ID: "43", User: viewer, Text: "entra em cheap-viewers.xyz"}, []string{"/delete 43", "/me @fulano, links só com !permit"}},
+		{"allowed link", chat.Message{User: viewer, Text: "https://github.com/moniquelive e www.gist.github.com"}, nil},
+		{"allowed path", chat.Message{User: viewer, Text: "https://twitch.tv/moniquelive/clip/Abc"}, nil},
+		{"other path", chat.Message{ID: "44", User: viewer, Text: "twitch.tv/outracanal"},
+			[]string{"/delete 44", "/me @fulano, links só com !permit"}},
+		{"permitted", chat.Message{User: friend, Text: "https://example.com/x"}, nil},
+		{"caps", chat.Message{User: viewer, Text: "PARA DE GRITAR NO CHAT"}, []string{"/me @fulano, sem gritar"}},
+		{"short caps", chat.Message{User: viewer, Text: "KKKKKK"}, nil},
+		{"vips may shout", chat.Message{User: vip, Text: "PARA DE GRITAR NO CHAT"}, nil},
+		{"flood", chat.Message{ID: "45", User: viewer, Text: "aaaaaaaaaaaaaaa"}, []string{"/delete 45"}},
+		{"emotes", chat.Message{User: viewer, Text: "Kappa Kappa", Emotes: 6}, []string{"/timeout fulano 60 emotes demais"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			decision, ok := engine.Check(tc.message)
+			assert.Equal(t, tc.expected != nil, ok)
+			assert.Equal(t, tc.expected, decision.Commands())
+		})
+	}
+}
+
+func TestCheckFollower(t *testing.T) {
+	var c commands.Commands
+	engine := moderation.New(c.HasPermission, func(string) bool { return false })
+	engine.Load(rules)
+
+	var tt = []struct {
+		name     string
+		follower string
+		expected string
+	}{
+		{"legit", "fulano", ""},
+		{"prefix", "Hoss00312_abc", "hoss"},
+		{"suffix", "xyz_hoss00312", "hoss"},
+		{"pattern", "qwerty123456", "bots"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			decision, _ := engine.CheckFollower(tc.follower)
+			assert.Equal(t, tc.expected, decision.Rule)
+		})
+	}
+}
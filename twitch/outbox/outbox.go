@@ -0,0 +1,179 @@
+// Package outbox is the single way out for chat messages: it keeps them in
+// order and within the Twitch rate limits.
+package outbox
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxLength is the longest message Twitch accepts.
+const MaxLength = 500
+
+// Limit is how many messages can be sent in a window. The zero Limit sends
+// everything right away.
+type Limit struct {
+	Messages int
+	Per      time.Duration
+}
+
+var (
+	// Normal is the limit for regular accounts.
+	Normal = Limit{Messages: 20, Per: 30 * time.Second}
+	// Moderator is the limit when the bot is a moderator (or the broadcaster).
+	Moderator = Limit{Messages: 100, Per: 30 * time.Second}
+	// Unlimited is for chats that aren't Twitch, like the console.
+	Unlimited = Limit{}
+
+	log = logrus.WithField("package", "outbox")
+)
+
+type Outbox struct {
+	send  func(msg string)
+	clock clock.Clock
+
+	mu     sync.Mutex
+	limit  Limit
+	queue  []string
+	sent   []time.Time // sliding window of the last sends
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+// New returns an outbox delivering messages through send. Call Run to start
+// sending.
+func New(send func(msg string), limit Limit, clock clock.Clock) *Outbox {
+	return &Outbox{
+		send:  send,
+		clock: clock,
+		limit: limit,
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// SetLimit changes the rate limit, e.g. once the bot finds out it is a mod.
+func (o *Outbox) SetLimit(limit Limit) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.limit = limit
+}
+
+// Say queues msg. Lines longer than MaxLength are split and a /color right
+// after another pending /color replaces it.
+func (o *Outbox) Say(msg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		log.Warnln("outbox fechado, descartando:", msg)
+		return
+	}
+	if isColor(msg) && len(o.queue) > 0 && isColor(o.queue[len(o.queue)-1]) {
+		o.queue[len(o.queue)-1] = msg
+		return
+	}
+	o.queue = append(o.queue, split(msg)...)
+	if depth := len(o.queue); o.limit.Messages > 0 && depth > o.limit.Messages {
+		log.Warnf("%d mensagens na fila", depth)
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len is the number of messages waiting to be sent.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.queue)
+}
+
+// Run sends the queued messages until Close is called and the queue is empty.
+func (o *Outbox) Run() {
+	defer close(o.done)
+	for {
+		if !o.waitMessage() {
+			return
+		}
+		for wait := o.waitLimit(); wait > 0; wait = o.waitLimit() {
+			<-o.clock.After(wait)
+		}
+		o.mu.Lock()
+		msg := o.queue[0]
+		o.queue = o.queue[1:]
+		o.sent = append(o.sent, o.clock.Now())
+		o.mu.Unlock()
+		o.send(msg)
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be sent.
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.wake)
+	}
+	o.mu.Unlock()
+	<-o.done
+}
+
+// waitMessage blocks until there's something to send. It returns false once
+// the outbox is closed and empty.
+func (o *Outbox) waitMessage() bool {
+	for {
+		o.mu.Lock()
+		pending, closed := len(o.queue) > 0, o.closed
+		o.mu.Unlock()
+		if pending {
+			return true
+		}
+		if closed {
+			return false
+		}
+		<-o.wake
+	}
+}
+
+// waitLimit returns how long until one more message fits in the window.
+func (o *Outbox) waitLimit() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.limit.Messages == 0 {
+		o.sent = nil
+		return 0
+	}
+	now := o.clock.Now()
+	for len(o.sent) > 0 && now.Sub(o.sent[0]) >= o.limit.Per {
+		o.sent = o.sent[1:]
+	}
+	if len(o.sent) < o.limit.Messages {
+		return 0
+	}
+	return o.sent[0].Add(o.limit.Per).Sub(now)
+}
+
+func isColor(msg string) bool {
+	return strings.HasPrefix(msg, "/color ")
+}
+
+// split breaks msg into lines that fit in MaxLength, keeping the /me prefix
+// on every one of them.
+func split(msg string) []string {
+	prefix := ""
+	if strings.HasPrefix(msg, "/me ") {
+		prefix, msg = "/me ", msg[len("/me "):]
+	}
+	lines := commands.WordWrap(msg, MaxLength-len(prefix))
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return lines
+}
@@ -0,0 +1,95 @@
+package outbox_test
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock jumps forward instead of sleeping.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+type sent struct {
+	msg string
+	at  time.Duration
+}
+
+func run(limit outbox.Limit, msgs ...string) []sent {
+	start := time.Unix(0, 0)
+	clock := &fakeClock{now: start}
+	var out []sent
+	o := outbox.New(func(msg string) {
+		out = append(out, sent{msg, clock.Now().Sub(start)})
+	}, limit, clock)
+	for _, msg := range msgs {
+		o.Say(msg)
+	}
+	go o.Run()
+	o.Close()
+	return out
+}
+
+func TestRateLimit(t *testing.T) {
+	var msgs []string
+	for i := 0; i < 45; i++ {
+		msgs = append(msgs, strconv.Itoa(i))
+	}
+	out := run(outbox.Normal, msgs...)
+
+	assert.Len(t, out, 45)
+	for i, s := range out {
+		assert.Equal(t, strconv.Itoa(i), s.msg, "out of order")
+		assert.Equal(t, time.Duration(i/20)*30*time.Second, s.at, "message %d", i)
+	}
+}
+
+func TestSay(t *testing.T) {
+	long := strings.Repeat("abcd ", 150)
+	var tt = []struct {
+		name     string
+		in       []string
+		expected []string
+	}{
+		{"keeps order", []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"merges colors", []string{"/color Red", "/color Blue", "/me oi"}, []string{"/color Blue", "/me oi"}},
+		{"colors apart", []string{"/color Red", "/me oi", "/color Blue", "/me tchau"},
+			[]string{"/color Red", "/me oi", "/color Blue", "/me tchau"}},
+		{"wraps long lines", []string{"/me " + long}, []string{
+			"/me " + strings.TrimSpace(long[:495]),
+			"/me " + strings.TrimSpace(long[495:]),
+		}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual []string
+			for _, s := range run(outbox.Moderator, tc.in...) {
+				assert.LessOrEqual(t, len(s.msg), outbox.MaxLength)
+				actual = append(actual, s.msg)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
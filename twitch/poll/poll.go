@@ -0,0 +1,230 @@
+// Package poll runs chat votes with a time limit: a poll is open for a while,
+// everyone gets one vote (that can be changed) and the result comes when the
+// window closes.
+package poll
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/sirupsen/logrus"
+)
+
+// TickInterval is how often Run checks if the poll is over.
+const TickInterval = time.Second
+
+// NoWinner is the Winner of a poll that tied or didn't reach the quorum.
+const NoWinner = -1
+
+var (
+	ErrNoPoll  = errors.New("nenhuma votação aberta")
+	ErrRunning = errors.New("já tem uma votação aberta")
+	ErrOption  = errors.New("opção inválida")
+
+	log = logrus.WithField("package", "poll")
+)
+
+// Poll is what is being voted.
+type Poll struct {
+	ID        string // filled in by Start when empty
+	Title     string
+	Options   []string
+	Quorum    int // votes the winning option needs
+	Duration  time.Duration
+	StartedBy string
+}
+
+// Tally is how a poll stands.
+type Tally struct {
+	Poll
+	Votes    []int // per option
+	EndsAt   time.Time
+	Closed   bool
+	Canceled bool // closed without a result
+	Winner   int  // index of the winning option once closed, or NoWinner
+}
+
+// Total is how many people voted.
+func (t Tally) Total() (total int) {
+	for _, votes := range t.Votes {
+		total += votes
+	}
+	return
+}
+
+// Runner keeps the open poll, one at a time.
+type Runner struct {
+	clock  clock.Clock
+	update func(Tally)
+	closed func(Tally)
+
+	mu      sync.Mutex
+	current *Tally
+	voters  map[string]int // user x option
+
+	publishing sync.Mutex // keeps the updates in the order of the changes
+}
+
+// New returns a runner calling update after every change of the open poll
+// (votes, closing, canceling) and closed with the result of the polls that
+// weren't canceled. update is called one at a time, in order, and must not
+// call back into the runner.
+func New(clock clock.Clock, update, closed func(Tally)) *Runner {
+	return &Runner{clock: clock, update: update, closed: closed}
+}
+
+// Start opens p.
+func (r *Runner) Start(p Poll) (Tally, error) {
+	if len(p.Options) < 2 {
+		return Tally{}, ErrOption
+	}
+	r.mu.Lock()
+	if r.current != nil {
+		r.mu.Unlock()
+		return Tally{}, ErrRunning
+	}
+	now := r.clock.Now()
+	if p.ID == "" {
+		p.ID = strconv.FormatInt(now.UnixNano(), 36)
+	}
+	r.current = &Tally{
+		Poll:   p,
+		Votes:  make([]int, len(p.Options)),
+		EndsAt: now.Add(p.Duration),
+		Winner: NoWinner,
+	}
+	r.voters = make(map[string]int)
+	tally := r.snapshot()
+	log.Infof("votação %q aberta por %v", p.Title, p.Duration)
+	r.publish(tally)
+	return tally, nil
+}
+
+// Vote counts user's vote for option (an index of Options). Voting again
+// changes the vote.
+func (r *Runner) Vote(user string, option int) (Tally, error) {
+	r.mu.Lock()
+	if r.current == nil {
+		r.mu.Unlock()
+		return Tally{}, ErrNoPoll
+	}
+	if option < 0 || option >= len(r.current.Options) {
+		r.mu.Unlock()
+		return Tally{}, ErrOption
+	}
+	if previous, ok := r.voters[user]; ok {
+		if previous == option {
+			tally := r.snapshot()
+			r.mu.Unlock()
+			return tally, nil
+		}
+		r.current.Votes[previous]--
+	}
+	r.voters[user] = option
+	r.current.Votes[option]++
+	tally := r.snapshot()
+	r.publish(tally)
+	return tally, nil
+}
+
+// Current returns the open poll, if any.
+func (r *Runner) Current() (Tally, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == nil {
+		return Tally{}, false
+	}
+	return r.snapshot(), true
+}
+
+// Close ends the open poll now, with a result.
+func (r *Runner) Close() (Tally, error) {
+	return r.finish(false)
+}
+
+// Cancel ends the open poll without a result.
+func (r *Runner) Cancel() (Tally, error) {
+	return r.finish(true)
+}
+
+// Tick closes the open poll once its time is up.
+func (r *Runner) Tick() {
+	r.mu.Lock()
+	due := r.current != nil && !r.clock.Now().Before(r.current.EndsAt)
+	r.mu.Unlock()
+	if due {
+		_, _ = r.Close()
+	}
+}
+
+// Run calls Tick every TickInterval until stop is closed.
+func (r *Runner) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-r.clock.After(TickInterval):
+			r.Tick()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (r *Runner) finish(cancel bool) (Tally, error) {
+	r.mu.Lock()
+	if r.current == nil {
+		r.mu.Unlock()
+		return Tally{}, ErrNoPoll
+	}
+	tally := r.snapshot()
+	r.current, r.voters = nil, nil
+	tally.Closed = true
+	tally.Canceled = cancel
+	if !cancel {
+		tally.Winner = winner(tally)
+	}
+	log.Infof("votação %q encerrada: %v (vencedora: %v)", tally.Title, tally.Votes, tally.Winner)
+	r.publish(tally)
+	if !cancel {
+		r.closed(tally)
+	}
+	return tally, nil
+}
+
+// publish hands tally to update, taking the publishing lock before letting
+// go of mu so the updates can't overtake each other. Must be called with mu
+// held, it's released.
+func (r *Runner) publish(tally Tally) {
+	r.publishing.Lock()
+	r.mu.Unlock()
+	defer r.publishing.Unlock()
+	r.update(tally)
+}
+
+// snapshot copies the open poll, so callers can't race with new votes.
+// Must be called with mu held.
+func (r *Runner) snapshot() Tally {
+	tally := *r.current
+	tally.Votes = append([]int(nil), r.current.Votes...)
+	return tally
+}
+
+// winner is the option with the most votes, as long as no other option has
+// as many and it reached the quorum.
+func winner(t Tally) int {
+	best, tied := NoWinner, false
+	for i, votes := range t.Votes {
+		switch {
+		case best == NoWinner || votes > t.Votes[best]:
+			best, tied = i, false
+		case votes == t.Votes[best]:
+			tied = true
+		}
+	}
+	if best == NoWinner || tied || t.Votes[best] == 0 || t.Votes[best] < t.Quorum {
+		return NoWinner
+	}
+	return best
+}
@@ -0,0 +1,117 @@
+package poll_test
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/poll"
+	"github.com/stretchr/testify/assert"
+)
+
+type vote struct {
+	user   string
+	option int
+}
+
+func TestRunner(t *testing.T) {
+	var tt = []struct {
+		name     string
+		quorum   int
+		votes    []vote
+		expected []int
+		winner   int
+	}{
+		{"most votes wins", 2, []vote{{"ana", 0}, {"bia", 0}, {"caio", 1}}, []int{2, 1}, 0},
+		{"tie", 1, []vote{{"ana", 0}, {"bia", 1}}, []int{1, 1}, poll.NoWinner},
+		{"no quorum", 3, []vote{{"ana", 0}, {"bia", 0}}, []int{2, 0}, poll.NoWinner},
+		{"nobody voted", 0, nil, []int{0, 0}, poll.NoWinner},
+		{"changing the vote", 1, []vote{{"ana", 0}, {"bia", 0}, {"ana", 1}, {"bia", 1}}, []int{0, 2}, 1},
+		{"voting twice", 2, []vote{{"ana", 0}, {"ana", 0}}, []int{1, 0}, poll.NoWinner},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Unix(0, 0))
+			var updates, results []poll.Tally
+			r := poll.New(clk,
+				func(tally poll.Tally) { updates = append(updates, tally) },
+				func(tally poll.Tally) { results = append(results, tally) })
+
+			_, err := r.Start(poll.Poll{Title: "Pular?", Options: []string{"pula", "fica"}, Quorum: tc.quorum, Duration: time.Minute})
+			assert.NoError(t, err)
+			for _, v := range tc.votes {
+				_, err := r.Vote(v.user, v.option)
+				assert.NoError(t, err)
+			}
+
+			clk.Advance(59 * time.Second)
+			r.Tick()
+			assert.Empty(t, results)
+
+			clk.Advance(time.Second)
+			r.Tick()
+			if assert.Len(t, results, 1) {
+				assert.Equal(t, tc.expected, results[0].Votes)
+				assert.Equal(t, tc.winner, results[0].Winner)
+				assert.True(t, results[0].Closed)
+			}
+			assert.Equal(t, results[0], updates[len(updates)-1])
+
+			_, ok := r.Current()
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	closed := 0
+	r := poll.New(clk, func(poll.Tally) {}, func(poll.Tally) { closed++ })
+
+	_, err := r.Vote("ana", 0)
+	assert.Equal(t, poll.ErrNoPoll, err)
+	_, err = r.Start(poll.Poll{Options: []string{"só uma"}})
+	assert.Equal(t, poll.ErrOption, err)
+
+	_, err = r.Start(poll.Poll{Options: []string{"a", "b"}, Duration: time.Minute})
+	assert.NoError(t, err)
+	_, err = r.Start(poll.Poll{Options: []string{"a", "b"}, Duration: time.Minute})
+	assert.Equal(t, poll.ErrRunning, err)
+	_, err = r.Vote("ana", 2)
+	assert.Equal(t, poll.ErrOption, err)
+
+	tally, err := r.Cancel()
+	assert.NoError(t, err)
+	assert.True(t, tally.Canceled)
+	assert.Equal(t, 0, closed)
+	_, err = r.Close()
+	assert.Equal(t, poll.ErrNoPoll, err)
+}
+
+func TestUpdatesInOrder(t *testing.T) {
+	var totals []int
+	r := poll.New(clock.NewFake(time.Unix(0, 0)), func(tally poll.Tally) {
+		totals = append(totals, tally.Total())
+	}, func(poll.Tally) {})
+	_, err := r.Start(poll.Poll{Options: []string{"a", "b"}, Duration: time.Minute})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = r.Vote(fmt.Sprint("user", i), i%2)
+		}(i)
+	}
+	wg.Wait()
+	_, _ = r.Close()
+
+	assert.Len(t, totals, 52)
+	assert.True(t, sort.IntsAreSorted(totals), "updates arrive in the order of the votes: %v", totals)
+	assert.Equal(t, 50, totals[len(totals)-1])
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+)
+
+// runQuotes exports the quotes from redis as JSON:
+//
+//	go run . quotes export > quotes.json
+func runQuotes(args []string) int {
+	if len(args) != 1 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "uso: quotes export")
+		return 2
+	}
+	red = redis.NewClient(&redis.Options{Addr: redisURL})
+	if _, err := red.Ping().Result(); err != nil {
+		fmt.Fprintln(os.Stderr, "sem redis:", err)
+		return 1
+	}
+	commands.SetRedis(red)
+	if err := commands.ExportQuotes(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
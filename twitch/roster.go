@@ -17,14 +17,6 @@ func notify() {
 	red.Publish(redisChannel, "updated")
 }
 
-func init() {
-	red = redis.NewClient(&redis.Options{Addr: redisURL})
-	if _, err := red.Ping().Result(); err != nil {
-		log.Println("RosterType.init > Sem redis...")
-		red = nil
-	}
-}
-
 func NewRoster() *Roster {
 	if red != nil {
 		red.Del(redisSetKey)
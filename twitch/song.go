@@ -1,15 +1,13 @@
 package main
 
-import "encoding/json"
+import (
+	"encoding/json"
 
-type songInfo struct {
-	ImgUrl  string `json:"imgUrl"`
-	SongUrl string `json:"songUrl"`
-	Title   string `json:"title"`
-	Artist  string `json:"artist"`
-	Length  int64  `json:"length"`
-}
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+)
 
-func parseSongInfo(bb []byte, songInfo *songInfo) error {
-	return json.Unmarshal(bb, &songInfo)
+// parseSongInfo reads the song payload dbus keeps in redis (the bare
+// payload, not the envelope).
+func parseSongInfo(bb []byte, songInfo *events.SongUpdated) error {
+	return json.Unmarshal(bb, songInfo)
 }
@@ -0,0 +1,77 @@
+// Package songqueue hands the song requests to spotify one at a time, only
+// when the song playing is about to end. Until then the requests stay in the
+// bot queue, where !wrongsong and !srclear can still take them out.
+package songqueue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/sirupsen/logrus"
+)
+
+// TickInterval is how often Run checks if it's time to forward a request.
+const TickInterval = 5 * time.Second
+
+var log = logrus.WithField("package", "songqueue")
+
+// Forwarder keeps track of when the song playing ends.
+type Forwarder struct {
+	clock   clock.Clock
+	forward func() bool
+
+	mu        sync.Mutex
+	lead      time.Duration
+	endsAt    time.Time
+	forwarded bool // a request already went to spotify during this song
+}
+
+// New returns a forwarder calling forward, lead before the end of each
+// song, until it forwards a request (returns true).
+func New(clock clock.Clock, lead time.Duration, forward func() bool) *Forwarder {
+	return &Forwarder{clock: clock, lead: lead, forward: forward}
+}
+
+// SetLead changes how long before the end of the song the next request is
+// forwarded.
+func (f *Forwarder) SetLead(lead time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lead = lead
+}
+
+// SongStarted tells a new song started playing and how long it lasts.
+func (f *Forwarder) SongStarted(length time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.endsAt = f.clock.Now().Add(length)
+	f.forwarded = false
+}
+
+// Tick forwards the next request once the song playing is about to end.
+// With nothing playing, requests are forwarded as soon as they arrive.
+func (f *Forwarder) Tick() {
+	f.mu.Lock()
+	due := !f.forwarded && !f.clock.Now().Before(f.endsAt.Add(-f.lead))
+	f.mu.Unlock()
+	if !due || !f.forward() {
+		return
+	}
+	log.Debugln("pedido de música enviado pro spotify")
+	f.mu.Lock()
+	f.forwarded = true
+	f.mu.Unlock()
+}
+
+// Run calls Tick every TickInterval until stop is closed.
+func (f *Forwarder) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-f.clock.After(TickInterval):
+			f.Tick()
+		case <-stop:
+			return
+		}
+	}
+}
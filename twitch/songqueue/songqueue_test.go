@@ -0,0 +1,73 @@
+package songqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/songqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwarder(t *testing.T) {
+	var tt = []struct {
+		name     string
+		elapsed  time.Duration
+		queued   int
+		expected int
+	}{
+		{"song just started", time.Second, 1, 0},
+		{"song near the end", 3*time.Minute - 15*time.Second, 1, 1},
+		{"only one request per song", 3*time.Minute - 15*time.Second, 2, 1},
+		{"nothing to forward", 3*time.Minute - 15*time.Second, 0, 0},
+		{"song over", 5 * time.Minute, 2, 1},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Unix(0, 0))
+			queued, forwarded := tc.queued, 0
+			f := songqueue.New(clk, 15*time.Second, func() bool {
+				if queued == 0 {
+					return false
+				}
+				queued--
+				forwarded++
+				return true
+			})
+			f.SongStarted(3 * time.Minute)
+
+			clk.Advance(tc.elapsed)
+			f.Tick()
+			f.Tick()
+			assert.Equal(t, tc.expected, forwarded)
+		})
+	}
+}
+
+func TestForwarderIdle(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	queued, forwarded := 0, 0
+	f := songqueue.New(clk, 15*time.Second, func() bool {
+		if queued == 0 {
+			return false
+		}
+		queued--
+		forwarded++
+		return true
+	})
+
+	// nothing playing: the first request goes as soon as it arrives
+	f.Tick()
+	queued = 2
+	f.Tick()
+	assert.Equal(t, 1, forwarded)
+
+	// and the next one waits for it to end
+	f.SongStarted(time.Minute)
+	f.Tick()
+	assert.Equal(t, 1, forwarded)
+	clk.Advance(time.Minute)
+	f.Tick()
+	assert.Equal(t, 2, forwarded)
+}
@@ -162,7 +162,22 @@ func (c *Client) EnqueueSong(id string) (err error) {
 		return errs[0]
 	}
 	if res.StatusCode != 204 {
-		return errors.New(http.StatusText(res.StatusCode))
+		return StatusError{Code: res.StatusCode}
 	}
 	return
 }
+
+// StatusError is an unexpected answer from the spotify API.
+type StatusError struct {
+	Code int
+}
+
+func (e StatusError) Error() string { return http.StatusText(e.Code) }
+
+// Permanent tells whether asking again won't help: a 4xx other than an
+// expired token or too many requests (e.g. the track isn't available or
+// there's no active device).
+func (e StatusError) Permanent() bool {
+	return e.Code >= 400 && e.Code < 500 &&
+		e.Code != http.StatusUnauthorized && e.Code != http.StatusTooManyRequests
+}
@@ -0,0 +1,112 @@
+// Package timers says the configured timer messages (hidrata, alonga, ...)
+// in chat once their interval has passed and the chat has been active enough.
+package timers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/sirupsen/logrus"
+)
+
+// TickInterval is how often Run checks for due timers.
+const TickInterval = 10 * time.Second
+
+var log = logrus.WithField("package", "timers")
+
+type timer struct {
+	commands.Timer
+	lastFired time.Time
+	messages  int
+}
+
+// Scheduler keeps track of when each timer was last said and how many chat
+// messages were seen since then.
+type Scheduler struct {
+	clock  clock.Clock
+	isLive func() bool
+	fire   func(commands.Timer)
+
+	mu     sync.Mutex
+	timers []*timer
+}
+
+// New returns a scheduler calling fire for every due timer. isLive is asked
+// once per tick, outside the lock, so it should be cheap.
+func New(clock clock.Clock, isLive func() bool, fire func(commands.Timer)) *Scheduler {
+	return &Scheduler{clock: clock, isLive: isLive, fire: fire}
+}
+
+// Load replaces the timer definitions. Timers that keep their name also keep
+// their state, so a hot reload doesn't make every timer fire at once.
+func (s *Scheduler) Load(defs []commands.Timer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := make(map[string]*timer)
+	for _, t := range s.timers {
+		previous[t.Name] = t
+	}
+	now := s.clock.Now()
+	s.timers = nil
+	for _, def := range defs {
+		if def.Interval <= 0 || len(def.Responses) == 0 {
+			log.Warnf("timer %q sem interval ou responses, ignorando", def.Name)
+			continue
+		}
+		t := &timer{Timer: def, lastFired: now}
+		if old, ok := previous[def.Name]; ok {
+			t.lastFired = old.lastFired
+			t.messages = old.messages
+		}
+		s.timers = append(s.timers, t)
+	}
+	log.Debugf("%d timers carregados", len(s.timers))
+}
+
+// Message counts a chat message towards every timer's min-messages.
+func (s *Scheduler) Message() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.timers {
+		t.messages++
+	}
+}
+
+// Tick fires every timer that is due.
+func (s *Scheduler) Tick() {
+	now := s.clock.Now()
+	live := s.isLive()
+	var due []commands.Timer
+	s.mu.Lock()
+	for _, t := range s.timers {
+		if now.Sub(t.lastFired) < time.Duration(t.Interval) || t.messages < t.MinMessages {
+			continue
+		}
+		if t.LiveOnly && !live {
+			continue
+		}
+		t.lastFired = now
+		t.messages = 0
+		due = append(due, t.Timer)
+	}
+	s.mu.Unlock()
+
+	for _, t := range due {
+		log.Debugf("disparando timer %q", t.Name)
+		s.fire(t)
+	}
+}
+
+// Run calls Tick every TickInterval until stop is closed.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-s.clock.After(TickInterval):
+			s.Tick()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package timers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
+	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/timers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	hidrata := commands.Timer{
+		Name:        "hidrata",
+		Interval:    commands.Duration(15 * time.Minute),
+		MinMessages: 2,
+		Responses:   []string{"/me bebe água!"},
+	}
+	alonga := commands.Timer{
+		Name:      "alonga",
+		Interval:  commands.Duration(time.Hour),
+		LiveOnly:  true,
+		Responses: []string{"/me alonga!"},
+	}
+	var tt = []struct {
+		name     string
+		live     bool
+		elapsed  time.Duration
+		messages int
+		expected []string
+	}{
+		{"nothing is due yet", true, 10 * time.Minute, 5, nil},
+		{"interval passed, chat too quiet", true, 20 * time.Minute, 1, nil},
+		{"interval passed, chat active", true, 20 * time.Minute, 2, []string{"hidrata"}},
+		{"live-only timer while live", true, time.Hour, 2, []string{"hidrata", "alonga"}},
+		{"live-only timer while offline", false, time.Hour, 2, []string{"hidrata"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Unix(0, 0))
+			var fired []string
+			s := timers.New(clk,
+				func() bool { return tc.live },
+				func(timer commands.Timer) { fired = append(fired, timer.Name) })
+			s.Load([]commands.Timer{hidrata, alonga})
+
+			clk.Advance(tc.elapsed)
+			for i := 0; i < tc.messages; i++ {
+				s.Message()
+			}
+			s.Tick()
+			assert.Equal(t, tc.expected, fired)
+		})
+	}
+}
+
+func TestSchedulerResetsAfterFiring(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	fired := 0
+	s := timers.New(clk, func() bool { return true }, func(commands.Timer) { fired++ })
+	s.Load([]commands.Timer{{
+		Name:      "hidrata",
+		Interval:  commands.Duration(time.Minute),
+		Responses: []string{"/me bebe água!"},
+	}})
+
+	clk.Advance(time.Minute)
+	s.Tick()
+	s.Tick()
+	assert.Equal(t, 1, fired)
+
+	// reload keeps the state of timers with the same name
+	clk.Advance(30 * time.Second)
+	s.Load([]commands.Timer{{
+		Name:      "hidrata",
+		Interval:  commands.Duration(time.Minute),
+		Responses: []string{"/me bebe mais água!"},
+	}})
+	clk.Advance(30 * time.Second)
+	s.Tick()
+	assert.Equal(t, 2, fired)
+}
@@ -3,28 +3,35 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"math/rand"
-	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat"
+	"github.com/moniquelive/moniquelive-bot/twitch/chat/twitchirc"
+	"github.com/moniquelive/moniquelive-bot/twitch/clock"
 	"github.com/moniquelive/moniquelive-bot/twitch/commands"
+	"github.com/moniquelive/moniquelive-bot/twitch/lockdown"
+	"github.com/moniquelive/moniquelive-bot/twitch/moderation"
+	"github.com/moniquelive/moniquelive-bot/twitch/outbox"
+	"github.com/moniquelive/moniquelive-bot/twitch/poll"
+	"github.com/moniquelive/moniquelive-bot/twitch/songqueue"
+	"github.com/moniquelive/moniquelive-bot/twitch/timers"
 
 	irc "github.com/gempir/go-twitch-irc/v2"
 	"github.com/go-redis/redis"
-	"github.com/streadway/amqp"
 )
 
 const (
-	moniqueID              = "4930146"
-	channel                = "moniquelive"
-	streamlabsID           = "105166207"
-	TtsReward              = "e706421e-01f7-48fd-a4c6-4393d1ba4ec8"
-	SpotifyReward          = "bf07c491-1ffb-4eb7-a7d8-5c9f2fe51818"
-	redisKey               = "twitch-bot:dbus:song-info"
-	twitchMessageTopicName = "twitch_message_delivered"
+	channel      = "moniquelive"
+	streamlabsID = "105166207"
+	redisKey     = "twitch-bot:dbus:song-info"
 )
 
 const (
@@ -39,20 +46,28 @@ const (
 )
 
 type Twitch struct {
-	client      *irc.Client
-	cmd         *commands.Commands
-	rstr        *Roster
-	amqpChannel *amqp.Channel
-	player      *Player
+	platform chat.Platform
+	cmd      *commands.Commands
+	rstr     *Roster
+	mq       commands.Publisher
+	player   *Player
+	timers   *timers.Scheduler
+	outbox   *outbox.Outbox
+	skipPoll *poll.Runner
+	polls    *poll.Runner
+	songs    *songqueue.Forwarder
+	mod      *moderation.Engine
+	modLog   *moderation.Log
+	lockdown *lockdown.Detector
 }
 
+var followRegex = regexp.MustCompile(`Thank you for following (.*?)!`)
+
 type Player struct {
 	red *redis.Client
 }
 
-func NewPlayer() (*Player, error) {
-	redisURL := os.Getenv("REDIS_URL")
-	red = redis.NewClient(&redis.Options{Addr: redisURL})
+func NewPlayer(red *redis.Client) (*Player, error) {
 	if _, err := red.Ping().Result(); err != nil {
 		return nil, fmt.Errorf("error pinging redis: %w", err)
 	}
@@ -60,12 +75,12 @@ func NewPlayer() (*Player, error) {
 }
 
 func (p Player) CurrentSong() string {
-	infoBytes, err := red.Get(redisKey).Bytes()
+	infoBytes, err := p.red.Get(redisKey).Bytes()
 	if err != nil {
 		log.Errorln("CurrentSong.Get:", err)
 		return "sem músicas no momento..."
 	}
-	var songInfo songInfo
+	var songInfo events.SongUpdated
 	err = parseSongInfo(infoBytes, &songInfo)
 	if err != nil {
 		log.Errorln("CurrentSong.Unmarshal:", err)
@@ -76,234 +91,531 @@ func (p Player) CurrentSong() string {
 		strings.ReplaceAll(songInfo.SongUrl, "https://open.spotify.com/track/", "https://song.link/s/"))
 }
 
-func NewTwitch(username, oauth string, cmd *commands.Commands, amqpChannel *amqp.Channel) (*Twitch, error) {
-	player, err := NewPlayer()
+func NewTwitch(platform chat.Platform, cmd *commands.Commands, publisher commands.Publisher) (*Twitch, error) {
+	player, err := NewPlayer(red)
 	if err != nil {
 		return nil, err
 	}
-	client := irc.NewClient(username, oauth)
 	t := &Twitch{
-		client:      client,
-		cmd:         cmd,
-		player:      player,
-		rstr:        NewRoster(),
-		amqpChannel: amqpChannel,
+		platform: platform,
+		cmd:      cmd,
+		player:   player,
+		rstr:     NewRoster(),
+		mq:       publisher,
 	}
-	client.OnConnect(func() {
+	t.outbox = outbox.New(platform.Say, outbox.Normal, clock.Real)
+	t.timers = timers.New(clock.Real, isLive, t.sayTimer)
+	t.timers.Load(cmd.Timers)
+	t.skipPoll = poll.New(clock.Real, t.publishPoll, t.skipPollClosed)
+	commands.SetSkipPoll(t.skipPoll)
+	t.polls = poll.New(clock.Real, t.publishPoll, t.pollClosed)
+	commands.SetPolls(t.polls)
+	t.songs = songqueue.New(clock.Real, cmd.SongRequests.LeadTime(), t.forwardSong)
+	t.mod = moderation.New(func(permission string, user *chat.User) bool {
+		return t.cmd.HasPermission(permission, user)
+	}, commands.Permitted)
+	t.mod.Load(cmd.Moderation)
+	cmd.SaveLinkPolicy()
+	t.modLog = moderation.NewLog(red)
+	t.lockdown = lockdown.New(clock.Real, t.waveDetected, t.lockdownEnded)
+	t.lockdown.Configure(cmd.Lockdown.Config())
+	commands.SetLockdown(t.lockdown)
+	platform.OnConnect(func() {
 		log.Println("*** OnConnect") // OnConnect attach callback to when a connection has been established
 		t.Say("/color seagreen")
 		t.Say("/me Tô na área!")
 		// client.Say(channel, "/slow 1")
 		t.Say("/uniquechat")
 	})
+	if p, ok := platform.(*twitchirc.Platform); ok {
+		t.watchIRC(p.Client)
+	}
+	platform.OnMessage(t.onMessage)
+	return t, nil
+}
+
+// watchIRC keeps the roster and the rate limits up to date with the events
+// only Twitch has.
+func (t *Twitch) watchIRC(client *irc.Client) {
+	client.OnUserStateMessage(func(message irc.UserStateMessage) {
+		user := twitchirc.User(message.User)
+		if t.cmd.HasPermission(commands.PermissionModerator, &user) {
+			t.outbox.SetLimit(outbox.Moderator)
+		} else {
+			t.outbox.SetLimit(outbox.Normal)
+		}
+	})
+
+	client.OnUserNoticeMessage(func(message irc.UserNoticeMessage) {
+		if message.MsgID != "raid" {
+			return
+		}
+		raiders, _ := strconv.Atoi(message.MsgParams["msg-param-viewerCount"])
+		log.Println(colorGreen, "*** RAID:", message.User.Name, raiders, colorReset)
+		t.lockdown.Raided()
+		user := twitchirc.User(message.User)
+		t.sayGreeting(commands.GreetingRaid, templateVars{Sender: &user, Raiders: raiders})
+	})
 
 	client.OnUserJoinMessage(func(message irc.UserJoinMessage) {
-		publishTwitchMessage(t.amqpChannel, message.Raw)
+		t.publishTwitchMessage(message.Raw)
 		log.Println(colorGreen, "*** OnUserJoinMessage >>>", message.User, colorReset)
 		t.rstr.AddUser(message.User)
 	})
 
 	client.OnUserPartMessage(func(message irc.UserPartMessage) {
-		publishTwitchMessage(t.amqpChannel, message.Raw)
+		t.publishTwitchMessage(message.Raw)
 		log.Println(colorRed, "*** OnUserPartMessage <<<", message.User, colorReset)
 		t.rstr.RemoveUser(message.User)
 	})
 
 	client.OnNamesMessage(func(message irc.NamesMessage) {
-		publishTwitchMessage(t.amqpChannel, message.Raw)
+		t.publishTwitchMessage(message.Raw)
 		log.Println(colorWhite, "*** OnNamesMessage:", len(message.Users), colorReset)
 		for _, user := range message.Users {
 			t.rstr.AddUser(user)
 		}
 	})
+}
+
+func (t *Twitch) onMessage(message chat.Message) {
+	cmd := t.cmd
+	//
+	// atualiza contadores do !cmds
+	//
+	t.publishTwitchMessage(message.Raw)
+	if leaveEarly := t.isTwitchRewards(message, cmd); leaveEarly {
+		return
+	}
+
+	if message.User.Name != username {
+		t.timers.Message()
+	}
 
-	client.OnPrivateMessage(func(message irc.PrivateMessage) {
-		//
-		// atualiza contadores do !cmds
-		//
-		publishTwitchMessage(t.amqpChannel, message.Raw)
-		if leaveEarly := t.isTwitchRewards(message, cmd); leaveEarly {
+	// imprime log
+	logWithColors(message.User.Name,
+		fmt.Sprintf("%s (%v): %s", message.User.DisplayName, message.User.ID, message.Text))
+
+	//
+	// moderação 🦠
+	//
+	if message.User.ID == streamlabsID {
+		t.checkFollower(message)
+		return
+	}
+	if message.User.Name != username {
+		if decision, ok := t.mod.Check(message); ok {
+			t.moderate(decision)
+			if decision.Action != commands.ModerationWarn {
+				return
+			}
+		}
+		if message.Tags["first-msg"] == "1" && t.lockdown.Chatted(message.User.Name) {
+			return
+		}
+		t.greet(message)
+		if commands.PollVote(&message.User, message.Text) {
+			return
+		}
+	}
+	// cai fora rápido se não for comando que começa com '!'
+	if message.Text == "!" || message.Text[0] != '!' {
+		return
+	}
+	// pula comandos marcados para ignorar
+	for _, ignoredCommand := range cmd.IgnoredCommands {
+		if strings.HasPrefix(message.Text, ignoredCommand) {
+			return
+		}
+	}
+	split := strings.Split(message.Text, " ")
+	action := split[0]
+	cmdLine := ""
+	if len(split) > 1 {
+		cmdLine = strings.Join(split[1:], " ")
+	}
+	//
+	// verifica se é um comando privilegiado
+	//
+	if !cmd.Allowed(action, &message.User) {
+		denied, err := t.parseTemplate(cmd.Denied(action), templateVars{Sender: &message.User, CmdLine: cmdLine})
+		if err != nil {
+			log.Errorln("erro de template:", err)
 			return
 		}
+		t.Say("/color firebrick")
+		t.Say(denied)
+		return
+	}
 
-		// imprime log
-		logWithColors(message.User.Name,
-			fmt.Sprintf("%s (%v): %s", message.User.DisplayName, message.User.ID, message.Message))
+	var (
+		responses []string
+		ok        bool
+	)
+	if responses, ok = cmd.ActionResponses[action]; !ok {
+		// comando desconhecido...
+		t.Say("/color firebrick")
+		t.Say("/me não conheço esse: " + message.Text)
+		return
+	}
 
-		//
-		// antivirus 🦠
-		//
-		if message.User.ID == streamlabsID {
-			t.antivirus(message)
+	if left := cmd.CooldownLeft(action, &message.User); left > 0 {
+		log.Printf("%s em cooldown para %s (%v)", action, message.User.Name, left)
+		if cmd.ActionCooldown[action].Reply == commands.CooldownWhisper {
+			t.platform.Whisper(message.User.Name, fmt.Sprintf("%s está em cooldown, tente de novo em %v",
+				action, commands.FormatDuration(left.Truncate(time.Second)+time.Second)))
+		}
+		return
+	}
+
+	var counter int64
+	if cmd.ActionCounter[action] != "" {
+		value, reply, err := cmd.Count(action, &message.User, cmdLine)
+		if err != nil {
+			log.Errorln("contador:", err)
+			t.Say("/me erro no contador: " + err.Error())
 			return
 		}
-		// cai fora rápido se não for comando que começa com '!'
-		if message.Message == "!" || message.Message[0] != '!' {
+		if reply != "" {
+			t.Say("/me " + reply)
 			return
 		}
-		// pula comandos marcados para ignorar
-		for _, ignoredCommand := range cmd.IgnoredCommands {
-			if strings.HasPrefix(message.Message, ignoredCommand) {
-				return
+		counter = value
+	}
+
+	vars := templateVars{
+		Sender:  &message.User,
+		CmdLine: cmdLine,
+		Extras:  cmd.ActionExtras[action], // parametros extras do comando
+		Counter: counter,
+		Reply:   message.Reply,
+	}
+	custom := cmd.IsCustom(action)
+	for _, unparsedResponse := range responses {
+		parsedResponse, err := t.parseTemplate(unparsedResponse, vars)
+		if err != nil {
+			// TODO: tentar reproduzir esta condição de erro...
+			split := strings.Split(err.Error(), ": ")
+			errMsg := split[len(split)-1]
+			errMsg = strings.ToUpper(errMsg[0:1]) + errMsg[1:]
+			t.Say("/color red")
+			t.Say("/me " + errMsg)
+			return
+		}
+		for _, split := range strings.Split(parsedResponse, "\n") {
+			if strings.TrimSpace(split) == "" {
+				continue
 			}
+			if custom && !commands.SafeCustomLine(split) {
+				log.Warnf("%s de %s barrado: %q", action, message.User.Name, split)
+				continue
+			}
+			t.Say(split)
 		}
-		split := strings.Split(message.Message, " ")
-		action := split[0]
-		cmdLine := ""
-		if len(split) > 1 {
-			cmdLine = strings.Join(split[1:], " ")
+	}
+	var logs []string
+	if logs, ok = cmd.ActionLogs[action]; !ok || len(logs) == 0 {
+		return
+	}
+	vars.Extras = []string{}
+	for _, unparsedLog := range logs {
+		parsedLog, err := t.parseTemplate(unparsedLog, vars)
+		if err != nil {
+			log.Println("erro de template:", err)
+			return
 		}
-		//
-		// verifica se é um comando privilegiado
-		//
-		admin, _ := cmd.ActionAdmin[action]
-		if admin && message.User.ID != moniqueID {
-			t.Say("/color firebrick")
-			t.Say("Desculpa ai " + message.User.DisplayName + ", esse é só da Mo!")
+		fmt.Println(colorCyan, parsedLog, colorReset)
+	}
+}
+
+// greet welcomes people talking for the first time or coming back after a
+// while.
+func (t Twitch) greet(message chat.Message) {
+	t.cmd.Greeting(&message.User, message.Tags["first-msg"] == "1", time.Now(), func(kind string, away time.Duration) {
+		if kind == "" {
 			return
 		}
+		t.sayGreeting(kind, templateVars{Sender: &message.User, AwayDays: int(away.Hours() / 24)})
+	})
+}
 
-		var (
-			responses []string
-			ok        bool
-		)
-		if responses, ok = cmd.ActionResponses[action]; !ok {
-			// comando desconhecido...
-			t.Say("/color firebrick")
-			t.Say("/me não conheço esse: " + message.Message)
+func (t Twitch) sayGreeting(kind string, vars templateVars) {
+	for _, unparsedResponse := range t.cmd.GreetingResponses(kind) {
+		parsedResponse, err := t.parseTemplate(unparsedResponse, vars)
+		if err != nil {
+			log.Errorf("saudação %q: erro de template: %v", kind, err)
 			return
 		}
+		for _, split := range strings.Split(parsedResponse, "\n") {
+			t.Say(split)
+		}
+	}
+	if !t.cmd.Greetings.Overlay {
+		return
+	}
+	if err := t.publishEvent(time.Minute, events.ViewerGreeted{
+		Kind:    kind,
+		User:    vars.Sender.DisplayName,
+		Viewers: vars.Raiders,
+	}); err != nil {
+		log.Errorln("sayGreeting > publishEvent:", err)
+	}
+}
 
-		extras, _ := cmd.ActionExtras[action] // parametros extras do comando
-		for _, unparsedResponse := range responses {
-			parsedResponse, err := t.parseTemplate(
-				&message.User,
-				unparsedResponse,
-				cmdLine,
-				extras)
+// isTwitchRewards handles the channel points rewards configured in rewards.
+// The unknown ones are remembered for !rewards and go on as normal messages.
+func (t Twitch) isTwitchRewards(message chat.Message, cmd *commands.Commands) bool {
+	rewardID, ok := message.Tags["custom-reward-id"]
+	if !ok {
+		return false
+	}
+	reward, ok := cmd.Reward(rewardID)
+	if !ok {
+		log.Warnf("recompensa desconhecida %v (%v: %v)", rewardID, message.User.Name, message.Text)
+		commands.UnknownReward(rewardID, &message.User, message.Text)
+		return false
+	}
+	log.Infof("recompensa %q resgatada por %v", reward.Name, message.User.Name)
+	switch reward.Action {
+	case commands.RewardTemplate:
+		for _, unparsedResponse := range reward.Responses {
+			parsedResponse, err := t.parseTemplate(unparsedResponse, templateVars{Sender: &message.User, CmdLine: message.Text})
 			if err != nil {
-				// TODO: tentar reproduzir esta condição de erro...
-				split := strings.Split(err.Error(), ": ")
-				errMsg := split[len(split)-1]
-				errMsg = strings.ToUpper(errMsg[0:1]) + errMsg[1:]
-				t.Say("/color red")
-				t.Say("/me " + errMsg)
-				return
+				log.Errorf("recompensa %q: erro de template: %v", reward.Name, err)
+				return true
 			}
 			for _, split := range strings.Split(parsedResponse, "\n") {
 				t.Say(split)
 			}
 		}
-		var logs []string
-		if logs, ok = cmd.ActionLogs[action]; !ok || len(logs) == 0 {
-			return
+	case commands.RewardSong:
+		if err := t.publishEvent(0, reward.Event(&message.User, message.Text)); err != nil {
+			log.Errorln("isTwitchRewards > publishEvent:", err)
+			t.Say(cmd.SongRequest(&message.User, message.Text))
 		}
-		for _, unparsedLog := range logs {
-			parsedLog, err := t.parseTemplate(
-				&message.User,
-				unparsedLog,
-				cmdLine,
-				[]string{})
-			if err != nil {
-				log.Println("erro de template:", err)
-				return
-			}
-			fmt.Println(colorCyan, parsedLog, colorReset)
+	default:
+		if err := t.publishEvent(time.Minute, reward.Event(&message.User, message.Text)); err != nil {
+			log.Errorln("isTwitchRewards > publishEvent:", err)
 		}
-	})
+	}
+	return true
+}
 
-	client.Join(channel)
-	return t, nil
+// checkFollower applies the follow-name rules to the followers StreamLabs
+// thanks in chat.
+func (t Twitch) checkFollower(message chat.Message) {
+	capture := followRegex.FindStringSubmatch(message.Text)
+	if capture == nil {
+		return
+	}
+	if decision, ok := t.mod.CheckFollower(capture[1]); ok {
+		t.moderate(decision)
+		return
+	}
+	t.lockdown.Arrived(capture[1])
 }
 
-func (t Twitch) isTwitchRewards(message irc.PrivateMessage, cmd *commands.Commands) bool {
-	//
-	// ve se é o comando da pérola
-	//
-	if rewardID, ok := message.Tags["custom-reward-id"]; ok && rewardID == TtsReward {
-		err := t.amqpChannel.Publish("amq.topic", createTtsTopicName, false, false, amqp.Publishing{
-			ContentType:     "text/plain",
-			ContentEncoding: "utf-8",
-			DeliveryMode:    2,
-			Expiration:      "60000",
-			Body:            []byte(message.Message),
-		})
-		if err != nil {
-			log.Errorln("client.OnPrivateMessage > amqpChannel.Publish:", err)
+// waveDetected locks the chat when a wave of follow bots starts and bans the
+// accounts, if configured to.
+func (t Twitch) waveDetected(wave lockdown.Wave) {
+	if wave.Started {
+		for _, command := range t.cmd.Lockdown.LockCommands() {
+			t.Say(command)
 		}
-		return true
 	}
-	//
-	// ve se é o comando do Spotify
-	//
-	if rewardID, ok := message.Tags["custom-reward-id"]; ok && rewardID == SpotifyReward {
-		t.Say(cmd.SongRequest(&message.User, message.Message))
-		return true
+	for _, account := range wave.Accounts {
+		if !t.cmd.Lockdown.Ban {
+			log.Println(colorRed, "!! ONDA DE BOTS:", account, colorReset)
+			continue
+		}
+		t.moderate(moderation.Decision{
+			Rule:   "lockdown",
+			Action: commands.ModerationBan,
+			User:   account,
+			Reason: "follow bot",
+			Text:   "onda de bots",
+		})
 	}
-	return false
 }
 
-func (t Twitch) antivirus(message irc.PrivateMessage) {
-	rex := regexp.MustCompile(`Thank you for following (.*?)!`)
-	if capture := rex.FindStringSubmatch(message.Message); capture != nil {
-		nick := capture[1]
-		if strings.HasPrefix(strings.ToLower(nick), "hoss00312_") ||
-			strings.HasSuffix(strings.ToLower(nick), "_hoss00312") {
-			t.Say("/ban " + nick)
-			log.Println(colorRed, "!! TCHAU QUERIDO:", nick)
-		}
+func (t Twitch) lockdownEnded() {
+	for _, command := range t.cmd.Lockdown.UnlockCommands() {
+		t.Say(command)
 	}
 }
 
-func publishTwitchMessage(amqpChannel *amqp.Channel, rawMessage string) {
-	err := amqpChannel.Publish("amq.topic", twitchMessageTopicName, false, false, amqp.Publishing{
-		ContentType:     "text/plain",
-		ContentEncoding: "utf-8",
-		DeliveryMode:    2,
-		Expiration:      "5000",
-		Body:            []byte(rawMessage),
-	})
+func (t Twitch) moderate(decision moderation.Decision) {
+	log.Println(colorRed, "!! MODERAÇÃO:", decision, colorReset)
+	t.modLog.Record(decision)
+	for _, command := range decision.Commands() {
+		t.Say(command)
+	}
+}
+
+func (t Twitch) publishTwitchMessage(rawMessage string) {
+	if err := t.publishEvent(5*time.Second, events.ChatMessage{Raw: rawMessage}); err != nil {
+		log.Errorln("publishTwitchMessage > publishEvent:", err)
+	}
+}
+
+func (t Twitch) publishEvent(ttl time.Duration, ev events.Event) error {
+	body, err := events.Encode(producerName, ev)
 	if err != nil {
-		log.Errorln("publishTwitchMessage > amqpChannel.Publish:", err)
+		return err
 	}
+	return t.mq.Publish(ev.Topic(), body, ttl)
 }
 
 func (t Twitch) Say(msg string) {
-	t.client.Say(channel, msg)
+	t.outbox.Say(msg)
 }
 
 func (t Twitch) Connect() error {
-	return t.client.Connect()
-}
-
-func (t Twitch) parseTemplate(
-	user *irc.User,
-	str,
-	cmdLine string,
-	extras []string,
-) (_ string, err error) {
-	var vars struct {
-		Roster   Roster
-		Player   Player
-		Sender   *irc.User
-		Commands string
-		CmdLine  string
-		Extras   []string
-		Command  commands.Commands
-	}
-	vars.Sender = user
-	vars.CmdLine = cmdLine
-	vars.Extras = extras
+	stop := make(chan struct{})
+	defer close(stop)
+	go t.timers.Run(stop)
+	go t.skipPoll.Run(stop)
+	go t.polls.Run(stop)
+	go t.songs.Run(stop)
+	go t.lockdown.Run(stop)
+	go t.outbox.Run()
+	return t.platform.Connect()
+}
+
+// Shutdown sends whatever is still queued and disconnects from the chat.
+func (t Twitch) Shutdown() {
+	log.Printf("enviando %d mensagens pendentes...", t.outbox.Len())
+	t.outbox.Close()
+	if err := t.platform.Disconnect(); err != nil {
+		log.Errorln("platform.Disconnect():", err)
+	}
+}
+
+// Reload picks up the timers, the song request settings, the moderation
+// rules and the lockdown config from a freshly reloaded commands.json.
+func (t Twitch) Reload() {
+	t.timers.Load(t.cmd.Timers)
+	t.mod.Load(t.cmd.Moderation)
+	t.cmd.SaveLinkPolicy()
+	t.lockdown.Configure(t.cmd.Lockdown.Config())
+	t.songs.SetLead(t.cmd.SongRequests.LeadTime())
+}
+
+// SongStarted is called when spotify starts a new song: the skip vote was
+// about the previous one and the next request goes near the end of this one.
+func (t Twitch) SongStarted(length time.Duration) {
+	commands.CancelSkipPoll()
+	t.songs.SongStarted(length)
+}
+
+func (t Twitch) forwardSong() bool {
+	request, err := commands.ForwardSongRequest(commands.EnqueueSpotify)
+	if errors.Is(err, commands.ErrSongDropped) {
+		log.Errorln("forwardSong > ForwardSongRequest:", err)
+		t.Say(fmt.Sprintf("/me @%v, o spotify não aceitou %v, tirei da fila 😢", request.User, request))
+		return false
+	}
+	if err != nil {
+		log.Errorln("forwardSong > ForwardSongRequest:", err)
+		return false
+	}
+	if request == nil {
+		return false
+	}
+	t.Say(fmt.Sprintf("/me Próxima: %v - pedida por @%v", request, request.User))
+	return true
+}
+
+func (t Twitch) pollClosed(tally poll.Tally) {
+	t.Say("/color DodgerBlue")
+	t.Say("/me " + commands.PollClosed(tally))
+}
+
+func (t Twitch) skipPollClosed(tally poll.Tally) {
+	t.Say("/color yellowgreen")
+	t.Say("/me " + commands.SkipPollClosed(tally))
+}
+
+// publishPoll shows how a poll stands on the overlay.
+func (t Twitch) publishPoll(tally poll.Tally) {
+	ev := events.PollUpdated{
+		ID:       tally.ID,
+		Title:    tally.Title,
+		Quorum:   tally.Quorum,
+		EndsAt:   tally.EndsAt,
+		Closed:   tally.Closed,
+		Canceled: tally.Canceled,
+	}
+	if left := time.Until(tally.EndsAt); left > 0 && !tally.Closed {
+		ev.SecondsLeft = int(left.Round(time.Second) / time.Second)
+	}
+	for i, option := range tally.Options {
+		ev.Options = append(ev.Options, events.PollOption{Name: option, Votes: tally.Votes[i]})
+	}
+	if tally.Winner != poll.NoWinner {
+		ev.Winner = tally.Options[tally.Winner]
+	}
+	if err := t.publishEvent(time.Minute, ev); err != nil {
+		log.Errorln("publishPoll > publishEvent:", err)
+	}
+}
+
+func (t Twitch) sayTimer(timer commands.Timer) {
+	bot := &chat.User{Name: username, DisplayName: username}
+	for _, unparsedResponse := range timer.Responses {
+		parsedResponse, err := t.parseTemplate(unparsedResponse, templateVars{Sender: bot})
+		if err != nil {
+			log.Errorf("timer %q: erro de template: %v", timer.Name, err)
+			return
+		}
+		for _, split := range strings.Split(parsedResponse, "\n") {
+			t.Say(split)
+		}
+	}
+}
+
+func isLive() bool {
+	live, err := commands.IsLive()
+	if err != nil {
+		log.Errorln("isLive:", err)
+	}
+	return live
+}
+
+// templateVars is what responses, logs and timers can use in their templates.
+type templateVars struct {
+	Roster   Roster
+	Player   Player
+	Sender   *chat.User
+	Reply    *chat.Reply // the message Sender answered, if any
+	Commands string
+	CmdLine  string
+	Extras   []string
+	Command  *commands.Commands
+	Counter  int64 // value of the command counter, after this use
+	AwayDays int   // days since the last message, in returning greetings
+	Raiders  int   // how many came along, in raid greetings
+}
+
+var templateFuncs = template.FuncMap{
+	"random":     func(choices []string) string { return choices[rand.Intn(len(choices))] },
+	"counter":    commands.Counter,
+	"counterAdd": commands.CounterAdd,
+}
+
+// checkTemplate is the commands.TemplateChecker for our templateVars.
+func checkTemplate(text string) error {
+	return commands.CheckTemplate(text, templateFuncs, templateVars{})
+}
+
+// parseTemplate executes str with vars, after filling in what every template sees.
+func (t Twitch) parseTemplate(str string, vars templateVars) (_ string, err error) {
 	vars.Commands = t.cmd.Actions()
-	vars.Command = *t.cmd
+	vars.Command = t.cmd
 	vars.Player = *t.player
 	vars.Roster = *t.rstr
 
-	fns := template.FuncMap{
-		"random": func(choices []string) string { return choices[rand.Intn(len(choices))] },
-	}
-
-	tmpl, err := template.New("json").Funcs(fns).Parse(str)
+	tmpl, err := template.New("json").Funcs(templateFuncs).Parse(str)
 	if err != nil {
 		return
 	}
@@ -7,7 +7,9 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func NewWatcher() {
+// NewWatcher reloads commands.json whenever it changes, calling onReload
+// afterwards.
+func NewWatcher(onReload func()) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatalln(err)
@@ -27,7 +29,11 @@ func NewWatcher() {
 				if event.Op&fsnotify.Write == fsnotify.Write {
 					log.Println("watchCommandsFSChange > modified file:", event.Name)
 					time.Sleep(1 * time.Second)
-					cmd.Reload()
+					if err := cmd.Reload(checkTemplate); err != nil {
+						log.Errorln("watchCommandsFSChange > mantendo os comandos anteriores:", err)
+						continue
+					}
+					onReload()
 				}
 				if event.Op&fsnotify.Create == fsnotify.Create && strings.HasSuffix(event.Name, "commands.json") {
 					log.Println("watchCommandsFSChange > re-watching:", event.Name)
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gempir/go-twitch-irc/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+const defaultSearchLimit = 50
+
+var (
+	bucketMessages = []byte("messages") // time + id -> ArchivedMessage
+	bucketUsers    = []byte("users")    // user \0 time + id -> nil
+	bucketWords    = []byte("words")    // word \0 time + id -> nil
+)
+
+// ArchivedMessage is a chat message as kept in the archive.
+type ArchivedMessage struct {
+	ID          string         `json:"id"`
+	Time        time.Time      `json:"time"`
+	User        string         `json:"user"`
+	DisplayName string         `json:"displayName"`
+	UserID      string         `json:"userId"`
+	Text        string         `json:"text"`
+	Badges      map[string]int `json:"badges,omitempty"`
+	Emotes      []string       `json:"emotes,omitempty"`
+	Session     string         `json:"session,omitempty"`
+}
+
+// Query filters the archive. Zero fields match everything.
+type Query struct {
+	User   string
+	Phrase string
+	From   time.Time
+	To     time.Time
+	Limit  int // defaults to defaultSearchLimit
+}
+
+// Archive keeps every chat message in a bolt file, indexed by user and by
+// word so searches don't have to read everything.
+type Archive struct {
+	db *bolt.DB
+}
+
+func OpenArchive(path string) (*Archive, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketMessages, bucketUsers, bucketWords} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Archive{db: db}, nil
+}
+
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+func newArchivedMessage(msg twitch.PrivateMessage, session string) ArchivedMessage {
+	var emotes []string
+	for _, emote := range msg.Emotes {
+		emotes = append(emotes, emote.Name)
+	}
+	return ArchivedMessage{
+		ID:          msg.ID,
+		Time:        msg.Time,
+		User:        msg.User.Name,
+		DisplayName: msg.User.DisplayName,
+		UserID:      msg.User.ID,
+		Text:        msg.Message,
+		Badges:      msg.User.Badges,
+		Emotes:      emotes,
+		Session:     session,
+	}
+}
+
+func (a *Archive) Add(m ArchivedMessage) error {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	m.User = strings.ToLower(m.User)
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	key := messageKey(m.Time, m.ID)
+	return a.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketMessages).Put(key, body); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketUsers).Put(indexKey(m.User, key), nil); err != nil {
+			return err
+		}
+		words := tx.Bucket(bucketWords)
+		for _, word := range tokenize(m.Text) {
+			if err := words.Put(indexKey(word, key), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Search returns the messages matching q, newest first.
+func (a *Archive) Search(q Query) (found []ArchivedMessage, err error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultSearchLimit
+	}
+	q.User = strings.ToLower(q.User)
+	phrase := strings.ToLower(q.Phrase)
+
+	// walks the smallest index we can: a word of the phrase, the user or
+	// every message
+	bucket, prefix := bucketMessages, []byte(nil)
+	if word := longest(tokenize(phrase)); word != "" {
+		bucket, prefix = bucketWords, indexKey(word, nil)
+	} else if q.User != "" {
+		bucket, prefix = bucketUsers, indexKey(q.User, nil)
+	}
+	// keys sort by time, so we go back from the first one after q.To
+	upper := bytes.Repeat([]byte{0xff}, 9)
+	if !q.To.IsZero() {
+		upper = append(messageKey(q.To, ""), 0xff)
+	}
+	err = a.db.View(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		c := tx.Bucket(bucket).Cursor()
+		k, _ := c.Seek(append(prefix[:len(prefix):len(prefix)], upper...))
+		if k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(found) < q.Limit; k, _ = c.Prev() {
+			key := k[len(prefix):]
+			if keyTime(key).Before(q.From) {
+				break
+			}
+			var m ArchivedMessage
+			if err := json.Unmarshal(messages.Get(key), &m); err != nil {
+				return err
+			}
+			if q.User != "" && m.User != q.User {
+				continue
+			}
+			if phrase != "" && !strings.Contains(strings.ToLower(m.Text), phrase) {
+				continue
+			}
+			found = append(found, m)
+		}
+		return nil
+	})
+	return
+}
+
+// messageKey sorts by time, the id tells apart messages sent at the same
+// nanosecond.
+func messageKey(t time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return append(key, id...)
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+}
+
+func indexKey(term string, key []byte) []byte {
+	index := make([]byte, 0, len(term)+1+len(key))
+	index = append(index, term...)
+	index = append(index, 0)
+	return append(index, key...)
+}
+
+// tokenize returns the distinct words (2+ letters or digits) of text, in
+// lower case.
+func tokenize(text string) (words []string) {
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) < 2 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	return
+}
+
+func longest(words []string) (longest string) {
+	for _, word := range words {
+		if len(word) > len(longest) {
+			longest = word
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveSearch(t *testing.T) {
+	a, err := OpenArchive(filepath.Join(t.TempDir(), "archive.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	day := time.Date(2021, 6, 30, 20, 0, 0, 0, time.Local)
+	for i, m := range []ArchivedMessage{
+		{User: "alice", Text: "Bom dia, chat!"},
+		{User: "bob", Text: "bom dia alice"},
+		{User: "alice", Text: "vamos de Go hoje?"},
+		{User: "Bob", Text: "Go é bom demais"},
+		{User: "carol", Text: "boa noite"},
+	} {
+		m.ID = string(rune('a' + i))
+		m.Time = day.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, a.Add(m))
+	}
+
+	var tt = []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{"everything, newest first", Query{}, []string{"e", "d", "c", "b", "a"}},
+		{"user", Query{User: "bob"}, []string{"d", "b"}},
+		{"user, any case", Query{User: "ALICE"}, []string{"c", "a"}},
+		{"word", Query{Phrase: "go"}, []string{"d", "c"}},
+		{"phrase", Query{Phrase: "bom dia"}, []string{"b", "a"}},
+		{"phrase and user", Query{Phrase: "bom", User: "bob"}, []string{"d", "b"}},
+		{"from", Query{From: day.Add(3 * time.Hour)}, []string{"e", "d"}},
+		{"to", Query{To: day.Add(time.Hour)}, []string{"b", "a"}},
+		{"range and word", Query{Phrase: "bom", From: day.Add(time.Hour), To: day.Add(3 * time.Hour)}, []string{"d", "b"}},
+		{"limit", Query{Limit: 2}, []string{"e", "d"}},
+		{"nothing", Query{Phrase: "rust"}, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := a.Search(tc.query)
+			assert.NoError(t, err)
+			var ids []string
+			for _, m := range found {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+
+	srv := httptest.NewServer(searchHandler(a))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/messages?user=alice&from=2021-06-30&to=2021-06-30&limit=1")
+	assert.NoError(t, err)
+	var found []ArchivedMessage
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&found))
+	resp.Body.Close()
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "vamos de Go hoje?", found[0].Text)
+	}
+	resp, err = http.Get(srv.URL + "/messages?from=ontem")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+}
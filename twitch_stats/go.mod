@@ -3,11 +3,15 @@ module github.com/moniquelive/moniquelive-bot/twitch_stats
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.3
 	github.com/gempir/go-twitch-irc/v2 v2.5.0
 	github.com/go-redis/redis v6.15.9+incompatible
-	github.com/google/uuid v1.2.0
+	github.com/moniquelive/moniquelive-bot/shared v0.0.0-00010101000000-000000000000
 	github.com/onsi/ginkgo v1.16.4 // indirect
 	github.com/onsi/gomega v1.13.0 // indirect
 	github.com/sirupsen/logrus v1.8.1
-	github.com/streadway/amqp v1.0.0
+	github.com/stretchr/testify v1.7.0
+	go.etcd.io/bbolt v1.3.6
 )
+
+replace github.com/moniquelive/moniquelive-bot/shared => ../shared
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// searchHandler serves the archive:
+//
+//	GET /messages?user=alice&q=bom+dia&from=2021-06-30&to=2021-07-01T12:00:00Z&limit=10
+func searchHandler(a *Archive) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "só GET", http.StatusMethodNotAllowed)
+			return
+		}
+		query, err := parseQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		found, err := a.Search(query)
+		if err != nil {
+			log.Errorln("searchHandler > Search:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found == nil {
+			found = []ArchivedMessage{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(found)
+	}
+}
+
+func parseQuery(r *http.Request) (q Query, err error) {
+	params := r.URL.Query()
+	q.User = params.Get("user")
+	q.Phrase = params.Get("q")
+	if q.From, err = parseTime(params.Get("from"), false); err != nil {
+		return q, fmt.Errorf("from: %w", err)
+	}
+	if q.To, err = parseTime(params.Get("to"), true); err != nil {
+		return q, fmt.Errorf("to: %w", err)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, fmt.Errorf("limit: %w", err)
+		}
+	}
+	return
+}
+
+// parseTime takes RFC 3339 times or plain dates. A plain date ending a range
+// includes the whole day.
+func parseTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
@@ -1,25 +1,30 @@
 package main
 
 import (
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gempir/go-twitch-irc/v2"
-	"github.com/google/uuid"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/shared/mq"
 	"github.com/sirupsen/logrus"
-	"github.com/streadway/amqp"
 )
 
 const (
-	queueName              = "ms.twitch_stats"
-	twitchMessageTopicName = "twitch_message_delivered"
+	queueName          = "ms.twitch_stats"
+	defaultArchivePath = "archive.db"
+	defaultArchiveAddr = ":9091"
 )
 
 var (
-	amqpURL = os.Getenv("RABBITMQ_URL")
-	log     = logrus.WithField("package", "main")
+	amqpURL     = os.Getenv("RABBITMQ_URL")
+	archivePath = os.Getenv("ARCHIVE_PATH")
+	archiveAddr = os.Getenv("ARCHIVE_ADDR")
+	log         = logrus.WithField("package", "main")
+	archive     *Archive
 )
 
 func init() {
@@ -37,102 +42,109 @@ func check(err error) {
 }
 
 func main() {
-	var err error
-
-	conn, err := amqp.Dial(amqpURL)
-	check(err)
-	defer conn.Close()
-	go func() { log.Debugf("closing: %s", <-conn.NotifyClose(make(chan *amqp.Error))) }()
-
-	log.Debugln("got Connection, getting Channel")
-	channel, err := conn.Channel()
-	check(err)
-	defer channel.Close()
-
-	log.Debugf("declaring Queue %q", queueName)
-	queue, err := channel.QueueDeclare(
-		queueName, // name of the queue
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // noWait
-		nil,       // arguments
-	)
-	check(err)
-
-	log.Debugf("binding Queue %q to amq.topic", queueName)
-	err = channel.QueueBind(queueName, twitchMessageTopicName, "amq.topic", false, nil)
-	check(err)
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReport(os.Args[2:]))
+	}
 
-	log.Debugln("Setting QoS")
-	err = channel.Qos(1, 0, true)
+	if archivePath == "" {
+		archivePath = defaultArchivePath
+	}
+	if archiveAddr == "" {
+		archiveAddr = defaultArchiveAddr
+	}
+	var err error
+	archive, err = OpenArchive(archivePath)
 	check(err)
+	defer archive.Close()
+
+	router := http.NewServeMux()
+	router.Handle("/messages", searchHandler(archive))
+	srv := &http.Server{Addr: archiveAddr, Handler: router}
+	go func() {
+		log.Println("Arquivo do chat em", archiveAddr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal("ListenAndServe:", err)
+		}
+	}()
 
-	log.Debugf("declared Queue (%q %d messages, %d consumers)", queue.Name, queue.Messages, queue.Consumers)
-
-	tag := uuid.NewString()
-	log.Debugf("starting Consume (tag:%q)", tag)
-	deliveries, err := channel.Consume(
-		queue.Name, // name
-		tag,        // consumerTag,
-		false,      // noAck
-		false,      // exclusive
-		false,      // noLocal
-		false,      // noWait
-		nil,        // arguments
-	)
+	client := mq.Dial(amqpURL)
+	err = client.Consume(mq.Topology{
+		Queue: queueName,
+		Topics: []string{
+			events.TopicChatMessage,
+			events.TopicStreamStarted,
+			events.TopicStreamStopped,
+			events.TopicStreamViewers,
+			events.TopicSongUpdated,
+		},
+	}, handle)
 	check(err)
 
-	done := make(chan struct{})
-	go handle(deliveries, done)
-
 	// wait for interrupt signal
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	select {
-	case <-stopChan:
-		// will close the deliveries channel
-		err = channel.Cancel(tag, true)
-		check(err)
-
-	// wait for go handle(...)
-	case <-done:
-		break
-	}
+	<-stopChan
 
+	_ = client.Close()
+	_ = srv.Close()
 	log.Debugln("AMQP consumer shutdown.")
 }
 
-func handle(deliveries <-chan amqp.Delivery, done chan<- struct{}) {
-	defer func() {
-		log.Debugln("handle: deliveries channel closed")
-		done <- struct{}{}
-	}()
-
-	log.Debugln("Listening...")
-	for delivery := range deliveries {
-		if delivery.Body == nil {
-			return
+func handle(delivery *mq.Delivery) error {
+	if len(delivery.Body) == 0 {
+		log.Debugln("empty message. ignoring...")
+		return nil
+	}
+	env, err := events.Decode(delivery.Body)
+	if err != nil {
+		return mq.Permanent(err)
+	}
+	switch env.Type {
+	case events.TopicStreamStarted:
+		var ev events.StreamStarted
+		if err := env.Unmarshal(&ev); err != nil {
+			return mq.Permanent(err)
 		}
-		if len(delivery.Body) == 0 {
-			log.Debugln("empty message. ignoring...")
-			_ = delivery.Ack(false)
-			break
+		startSession(ev)
+		return nil
+	case events.TopicStreamStopped:
+		var ev events.StreamStopped
+		if err := env.Unmarshal(&ev); err != nil {
+			return mq.Permanent(err)
 		}
-		body := string(delivery.Body)
-		//log.Infoln("DELIVERY:", body)
-		switch msg := twitch.ParseMessage(body).(type) {
-		case *twitch.UserJoinMessage:
-			parseUserJoin(*msg)
-		case *twitch.UserPartMessage:
-			parseUserPart(*msg)
-		case *twitch.NamesMessage:
-			parseNames(*msg)
-		case *twitch.PrivateMessage:
-			parsePrivate(*msg)
-			//default:
-			//	log.Debugf("Desconhecido: %T\n", msg)
+		stopSession(ev.ID, env.Timestamp)
+		return nil
+	case events.TopicStreamViewers:
+		var ev events.StreamViewers
+		if err := env.Unmarshal(&ev); err != nil {
+			return mq.Permanent(err)
 		}
-		_ = delivery.Ack(false)
+		updateViewers(ev)
+		return nil
+	case events.TopicSongUpdated:
+		var ev events.SongUpdated
+		if err := env.Unmarshal(&ev); err != nil {
+			return mq.Permanent(err)
+		}
+		songPlayed(ev)
+		return nil
+	}
+	var chatMessage events.ChatMessage
+	if err := env.Unmarshal(&chatMessage); err != nil {
+		return mq.Permanent(err)
+	}
+	//log.Infoln("DELIVERY:", chatMessage.Raw)
+	switch msg := twitch.ParseMessage(chatMessage.Raw).(type) {
+	case *twitch.UserJoinMessage:
+		parseUserJoin(*msg)
+	case *twitch.UserPartMessage:
+		parseUserPart(*msg)
+	case *twitch.NamesMessage:
+		parseNames(*msg)
+	case *twitch.PrivateMessage:
+		parsePrivate(*msg)
+		//default:
+		//	log.Debugf("Desconhecido: %T\n", msg)
 	}
+	return nil
 }
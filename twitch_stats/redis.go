@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"regexp"
 	"strings"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/gempir/go-twitch-irc/v2"
 	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/shared/links"
 )
 
 const (
@@ -15,7 +17,16 @@ const (
 	userRosterSet         = "twitch-bot:twitch_stats:user_roster"
 	userDataKeySeenAt     = "twitch-bot:twitch_stats:seen_at:"
 	userDataKeyURLs       = "twitch-bot:twitch_stats:urls:"
-	userDataKeyCommands   = "twitch-bot:twitch_stats:command:"
+	dayExpireDuration     = 8 * 24 * time.Hour
+
+	// rankings (sorted sets) kept per period: the stream session (e.g.
+	// ...:chatters:session:39930139531), the day (e.g.
+	// ...:chatters:day:2021-06-30) and all time
+	statsKeyChatters = "twitch-bot:twitch_stats:chatters:"
+	statsKeyCommands = "twitch-bot:twitch_stats:commands:"
+	periodSession    = "session:"
+	periodDay        = "day:"
+	periodAll        = "all"
 )
 
 var (
@@ -39,6 +50,9 @@ func parseUserJoin(msg twitch.UserJoinMessage) {
 	red.SAdd(userRosterSet, userName)
 	setDefaultExpiration(userRosterSet)
 	red.SetNX(userDataKeySeenAt+userName, time.Now().Unix(), defaultExpireDuration)
+	if session := liveSession(); session != "" {
+		red.SAdd(sessionKey(session, sessionJoins), userName)
+	}
 }
 
 func parseUserPart(msg twitch.UserPartMessage) {
@@ -56,21 +70,48 @@ func parseNames(msg twitch.NamesMessage) {
 func parsePrivate(msg twitch.PrivateMessage) {
 	log.Infof("PvtMessage: %v (%v): %v\n", msg.User.Name, msg.User.ID, msg.Message)
 
-	parseHttps(msg)
-	parseCommandsCounter(msg)
+	session := liveSession()
+	if archive != nil {
+		if err := archive.Add(newArchivedMessage(msg, session)); err != nil {
+			log.Errorln("parsePrivate > archive.Add:", err)
+		}
+	}
+	parseHttps(msg, session)
+	parseCommandsCounter(msg, session)
+	if countStat(statsKeyChatters, msg.User.Name, msg.Time, session) == 1 && session != "" {
+		red.SAdd(sessionKey(session, sessionNewChatters), msg.User.Name)
+	}
 }
 
-func parseCommandsCounter(msg twitch.PrivateMessage) {
-	if !strings.HasPrefix(msg.Message, "!") {
+func parseCommandsCounter(msg twitch.PrivateMessage, session string) {
+	if !strings.HasPrefix(msg.Message, "!") || msg.Message == "!" {
 		return
 	}
-	// !ola que tal -> split -> ["!ola", "que", "tal"] -> [0] -> !ola -> [1:] -> ola
-	command := strings.Split(msg.Message, " ")[0][1:]
-	red.Incr(userDataKeyCommands + command)
-	setDefaultExpiration(userDataKeyCommands + command)
+	// !ola que tal -> split -> ["!ola", "que", "tal"] -> [0] -> !ola
+	command := strings.Split(msg.Message, " ")[0]
+	countStat(statsKeyCommands, command, msg.Time, session)
 }
 
-func parseHttps(msg twitch.PrivateMessage) {
+// countStat adds one to member in the session (if there's one live), day
+// and all time rankings and returns the all time score.
+func countStat(prefix, member string, now time.Time, session string) float64 {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if session != "" {
+		red.ZIncrBy(prefix+periodSession+session, 1, member)
+	}
+
+	day := prefix + periodDay + now.Format("2006-01-02")
+	red.ZIncrBy(day, 1, member)
+	if red.TTL(day).Val() == -1*time.Second {
+		red.Expire(day, dayExpireDuration)
+	}
+
+	return red.ZIncrBy(prefix+periodAll, 1, member).Val()
+}
+
+func parseHttps(msg twitch.PrivateMessage, session string) {
 	// regexp:
 	//  adiciona url em lista de urls para usuário
 	//  conta quantas vezes demos cada comando...
@@ -81,8 +122,34 @@ func parseHttps(msg twitch.PrivateMessage) {
 			urls = append(urls, s)
 		}
 	}
+	if len(urls) == 0 || !allowedPost(msg) {
+		return
+	}
 	red.LPush(userDataKeyURLs+msg.User.Name, urls)
 	setDefaultExpiration(userDataKeyURLs + msg.User.Name)
+	if session != "" {
+		for _, u := range urls {
+			red.RPush(sessionKey(session, sessionURLs), msg.User.Name+" "+u)
+		}
+	}
+}
+
+// allowedPost tells whether the bot keeps msg, going by the links policy it
+// leaves in redis: it deletes the rest. Without a policy every post stays.
+func allowedPost(msg twitch.PrivateMessage) bool {
+	body, err := red.Get(links.PolicyRedisKey).Bytes()
+	if err != nil {
+		return true
+	}
+	var policy links.Policy
+	if err := json.Unmarshal(body, &policy); err != nil {
+		log.Errorln("allowedPost > Unmarshal:", err)
+		return true
+	}
+	if policy.Exempt(msg.User.Name, msg.User.Badges) || red.Exists(links.PermitRedisKeyPrefix+msg.User.Name).Val() == 1 {
+		return true
+	}
+	return links.AllAllowed(msg.Message, policy.Allow)
 }
 
 func setDefaultExpiration(key string) {
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gempir/go-twitch-irc/v2"
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/shared/links"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHttps(t *testing.T) {
+	fake, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	red = redis.NewClient(&redis.Options{Addr: fake.Addr()})
+
+	post := func(user string, badges map[string]int, text string) {
+		parseHttps(twitch.PrivateMessage{User: twitch.User{Name: user, Badges: badges}, Message: text}, "")
+	}
+	urls := func(user string) []string {
+		return red.LRange(userDataKeyURLs+user, 0, -1).Val()
+	}
+
+	post("alice", nil, "https://example.com")
+	assert.Equal(t, []string{"https://example.com"}, urls("alice"), "no policy, no filter")
+
+	assert.NoError(t, red.Set(links.PolicyRedisKey,
+		`{"allow": ["github.com", "twitch.tv/*/clip"], "badges": ["broadcaster", "moderator"]}`, 0).Err())
+	red.Set(links.PermitRedisKeyPrefix+"carol", 1, 0)
+
+	post("bob", nil, "https://github.com/moniquelive")
+	post("bob", nil, "https://github.com/x e https://example.com")
+	post("bob", nil, "https://twitch.tv/moniquelive/clip/Abc")
+	post("carol", nil, "https://example.com/permitido")
+	post("dave", map[string]int{"moderator": 1}, "https://example.com/mod")
+	assert.Equal(t, []string{"https://twitch.tv/moniquelive/clip/Abc", "https://github.com/moniquelive"}, urls("bob"))
+	assert.Equal(t, []string{"https://example.com/permitido"}, urls("carol"))
+	assert.Equal(t, []string{"https://example.com/mod"}, urls("dave"))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const reportTopSize = 10
+
+var errNoSession = errors.New("sessão não encontrada")
+
+// Report sums up a stream session.
+type Report struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Source      string    `json:"source"`
+	StartedAt   time.Time `json:"startedAt"`
+	EndedAt     time.Time `json:"endedAt"`
+	PeakViewers int       `json:"peakViewers"`
+	Joins       int64     `json:"joins"`
+	Chatters    int64     `json:"chatters"`
+	Messages    int64     `json:"messages"`
+	NewChatters []string  `json:"newChatters"`
+	TopChatters []Rank    `json:"topChatters"`
+	TopCommands []Rank    `json:"topCommands"`
+	Songs       []string  `json:"songs"`
+	URLs        []string  `json:"urls"`
+}
+
+type Rank struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+func buildReport(id string) (*Report, error) {
+	info := red.HGetAll(sessionKey(id, "")).Val()
+	if len(info) == 0 {
+		return nil, fmt.Errorf("%w: %q", errNoSession, id)
+	}
+	r := &Report{
+		ID:     id,
+		Title:  info["title"],
+		Source: info["source"],
+	}
+	r.StartedAt = unixField(info, "started_at")
+	r.EndedAt = unixField(info, "ended_at")
+	r.PeakViewers, _ = strconv.Atoi(info["peak_viewers"])
+
+	chatters := statsKeyChatters + periodSession + id
+	r.Joins = red.SCard(sessionKey(id, sessionJoins)).Val()
+	r.Chatters = red.ZCard(chatters).Val()
+	r.TopChatters = ranking(chatters, &r.Messages)
+	r.TopCommands = ranking(statsKeyCommands+periodSession+id, nil)
+	r.NewChatters = red.SMembers(sessionKey(id, sessionNewChatters)).Val()
+	sort.Strings(r.NewChatters)
+	r.Songs = red.LRange(sessionKey(id, sessionSongs), 0, -1).Val()
+	r.URLs = red.LRange(sessionKey(id, sessionURLs), 0, -1).Val()
+	return r, nil
+}
+
+func unixField(info map[string]string, field string) time.Time {
+	unix, err := strconv.ParseInt(info[field], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+// ranking returns the first reportTopSize entries of a sorted set. If total
+// isn't nil it gets the sum of every score.
+func ranking(key string, total *int64) (top []Rank) {
+	for i, z := range red.ZRevRangeWithScores(key, 0, -1).Val() {
+		if total != nil {
+			*total += int64(z.Score)
+		}
+		if i < reportTopSize {
+			top = append(top, Rank{Name: z.Member.(string), Count: int64(z.Score)})
+		}
+	}
+	return
+}
+
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Live %v\n\n", r.ID)
+	if r.Title != "" {
+		fmt.Fprintf(&b, "**%v**\n\n", r.Title)
+	}
+	fmt.Fprintf(&b, "- Início: %v\n", r.StartedAt.Format("02/01/2006 15:04"))
+	if !r.EndedAt.IsZero() {
+		fmt.Fprintf(&b, "- Fim: %v (%v)\n", r.EndedAt.Format("02/01/2006 15:04"), r.EndedAt.Sub(r.StartedAt))
+	} else {
+		b.WriteString("- Fim: ainda rolando\n")
+	}
+	fmt.Fprintf(&b, "- Pico de viewers: %v\n", r.PeakViewers)
+	fmt.Fprintf(&b, "- Entradas no chat: %v\n", r.Joins)
+	fmt.Fprintf(&b, "- Mensagens: %v de %v pessoas\n", r.Messages, r.Chatters)
+	fmt.Fprintf(&b, "- Chatters novos: %v\n", len(r.NewChatters))
+
+	markdownList(&b, "Chatters novos", r.NewChatters)
+	markdownRanking(&b, "Top chatters", r.TopChatters)
+	markdownRanking(&b, "Top comandos", r.TopCommands)
+	markdownList(&b, "Músicas", r.Songs)
+	markdownList(&b, "URLs", r.URLs)
+	return b.String()
+}
+
+func markdownList(b *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %v\n\n", title)
+	for _, item := range items {
+		fmt.Fprintf(b, "- %v\n", item)
+	}
+}
+
+func markdownRanking(b *strings.Builder, title string, ranks []Rank) {
+	if len(ranks) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %v\n\n", title)
+	for i, rank := range ranks {
+		fmt.Fprintf(b, "%v. %v (%v)\n", i+1, rank.Name, rank.Count)
+	}
+}
+
+// runReport prints the report of a session (the latest one by default):
+//
+//	go run . report -format md 39930139531
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "json", "json ou md")
+	_ = flags.Parse(args)
+
+	if red == nil {
+		fmt.Fprintln(os.Stderr, "sem redis...")
+		return 1
+	}
+	id := flags.Arg(0)
+	if id == "" {
+		id = red.Get(sessionLastKey).Val()
+	}
+	report, err := buildReport(id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	switch *format {
+	case "md":
+		fmt.Print(report.Markdown())
+	case "json":
+		body, err := report.JSON()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(body))
+	default:
+		fmt.Fprintf(os.Stderr, "formato %q desconhecido\n", *format)
+		return 1
+	}
+	return 0
+}
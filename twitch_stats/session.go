@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+)
+
+// A stream session is a hash (...:session:<id>) with what we know about the
+// stream plus a few keys (...:session:<id>:<what>) with what happened in it.
+const (
+	sessionKeyPrefix = "twitch-bot:twitch_stats:session:"
+	sessionLiveKey   = sessionKeyPrefix + "live" // id of the session going on right now
+	sessionLastKey   = sessionKeyPrefix + "last" // id of the latest session, live or not
+
+	sessionJoins       = "joins"        // set of users that joined
+	sessionNewChatters = "new_chatters" // set of users that talked for the first time ever
+	sessionURLs        = "urls"         // list of "user url"
+	sessionSongs       = "songs"        // list of "artist - title"
+	sessionReport      = "report"       // the JSON report, once the session ends
+)
+
+func sessionKey(id, what string) string {
+	if what == "" {
+		return sessionKeyPrefix + id
+	}
+	return sessionKeyPrefix + id + ":" + what
+}
+
+// liveSession returns the id of the session going on or "" when offline.
+func liveSession() string {
+	return red.Get(sessionLiveKey).Val()
+}
+
+func startSession(ev events.StreamStarted) {
+	live := liveSession()
+	if live == ev.ID {
+		return // helix keeps telling us the same stream is on
+	}
+	if live != "" {
+		stopSession(live, ev.StartedAt)
+	}
+	if ev.StartedAt.IsZero() {
+		ev.StartedAt = time.Now()
+	}
+	log.Infof("Sessão %v começou (%v): %v", ev.ID, ev.Source, ev.Title)
+	key := sessionKey(ev.ID, "")
+	red.HSetNX(key, "id", ev.ID)
+	red.HSetNX(key, "title", ev.Title)
+	red.HSetNX(key, "source", ev.Source)
+	red.HSetNX(key, "started_at", ev.StartedAt.Unix())
+	red.HDel(key, "ended_at")
+	red.Set(sessionLiveKey, ev.ID, 0)
+	red.Set(sessionLastKey, ev.ID, 0)
+}
+
+func stopSession(id string, now time.Time) {
+	if id == "" || id != liveSession() {
+		log.Debugf("Sessão %q não está rolando, ignorando...", id)
+		return
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	red.HSet(sessionKey(id, ""), "ended_at", now.Unix())
+	red.Del(sessionLiveKey)
+
+	report, err := buildReport(id)
+	if err != nil {
+		log.Errorln("stopSession > buildReport:", err)
+		return
+	}
+	if body, err := report.JSON(); err == nil {
+		red.Set(sessionKey(id, sessionReport), body, 0)
+	}
+	log.Infof("Sessão %v terminou:\n%v", id, report.Markdown())
+}
+
+func updateViewers(ev events.StreamViewers) {
+	if ev.ID != liveSession() {
+		return
+	}
+	key := sessionKey(ev.ID, "")
+	if peak, _ := red.HGet(key, "peak_viewers").Int(); ev.Viewers > peak {
+		red.HSet(key, "peak_viewers", ev.Viewers)
+	}
+}
+
+func songPlayed(ev events.SongUpdated) {
+	session := liveSession()
+	if session == "" {
+		return
+	}
+	red.RPush(sessionKey(session, sessionSongs), ev.Artist+" - "+ev.Title)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gempir/go-twitch-irc/v2"
+	"github.com/go-redis/redis"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionReport(t *testing.T) {
+	fake, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	red = redis.NewClient(&redis.Options{Addr: fake.Addr()})
+
+	start := time.Date(2021, 6, 30, 20, 0, 0, 0, time.Local)
+	say := func(user, text string) {
+		parsePrivate(twitch.PrivateMessage{User: twitch.User{Name: user}, Message: text, Time: start})
+	}
+
+	say("alice", "antes da live") // not in any session
+	startSession(events.StreamStarted{ID: "42", Title: "Go!", StartedAt: start, Source: events.StreamSourceHelix})
+	startSession(events.StreamStarted{ID: "42", Title: "Go!", StartedAt: start.Add(time.Minute)})
+	parseUserJoin(twitch.UserJoinMessage{User: "bob"})
+	say("alice", "oi")
+	say("bob", "!rainbow")
+	say("bob", "olha https://monique.dev")
+	say("alice", "!rainbow 🌈")
+	updateViewers(events.StreamViewers{ID: "42", Viewers: 10})
+	updateViewers(events.StreamViewers{ID: "42", Viewers: 7})
+	updateViewers(events.StreamViewers{ID: "outra", Viewers: 1000})
+	songPlayed(events.SongUpdated{Artist: "Daft Punk", Title: "One More Time"})
+	stopSession("42", start.Add(2*time.Hour))
+	say("alice", "depois da live") // not in any session
+	assert.Equal(t, "", liveSession())
+
+	report, err := buildReport("42")
+	assert.NoError(t, err)
+	assert.Equal(t, &Report{
+		ID:          "42",
+		Title:       "Go!",
+		Source:      events.StreamSourceHelix,
+		StartedAt:   start,
+		EndedAt:     start.Add(2 * time.Hour),
+		PeakViewers: 10,
+		Joins:       1,
+		Chatters:    2,
+		Messages:    4,
+		NewChatters: []string{"bob"},
+		TopChatters: []Rank{{"bob", 2}, {"alice", 2}},
+		TopCommands: []Rank{{"!rainbow", 2}},
+		Songs:       []string{"Daft Punk - One More Time"},
+		URLs:        []string{"bob https://monique.dev"},
+	}, report)
+	assert.Contains(t, report.Markdown(), "- Mensagens: 4 de 2 pessoas\n")
+	assert.NotEmpty(t, red.Get(sessionKey("42", sessionReport)).Val())
+
+	_, err = buildReport("43")
+	assert.ErrorIs(t, err, errNoSession)
+}
@@ -3,6 +3,7 @@ port module Main exposing (..)
 import Animation exposing (percent)
 import Animation.Spring.Presets exposing (wobbly)
 import Browser
+import Dict exposing (Dict)
 import Html exposing (..)
 import Html.Attributes exposing (..)
 import Json.Decode as D
@@ -39,7 +40,7 @@ port messageReceiver : (String -> msg) -> Sub msg
 
 type alias WebsocketMessage =
     { action : String
-    , payload : String
+    , payload : D.Value
     }
 
 
@@ -55,6 +56,50 @@ type alias Model =
     , currentSongStyle : Animation.State
     , marqueeMessage : String
     , marqueeStyle : Animation.State
+    , counters : Dict String Int
+    , greeting : Greeting
+    , greetingStyle : Animation.State
+    , poll : Poll
+    , pollStyle : Animation.State
+    , effect : Effect
+    , effectStyle : Animation.State
+    }
+
+
+type alias Greeting =
+    { kind : String
+    , user : String
+    , viewers : Int
+    }
+
+
+type alias Effect =
+    { name : String
+    , user : String
+    , text : String
+    }
+
+
+type alias Poll =
+    { title : String
+    , options : List PollOption
+    , quorum : Int
+    , secondsLeft : Int
+    , closed : Bool
+    , canceled : Bool
+    , winner : String
+    }
+
+
+type alias PollOption =
+    { name : String
+    , votes : Int
+    }
+
+
+type alias Counter =
+    { name : String
+    , value : Int
     }
 
 
@@ -64,6 +109,13 @@ init _ =
       , currentSongStyle = Animation.styleWith (Animation.spring wobbly) [ Animation.translate (percent 115) (percent 0) ]
       , marqueeMessage = ""
       , marqueeStyle = Animation.styleWith (Animation.spring wobbly) [ Animation.translate (percent 0) (percent 100) ]
+      , counters = Dict.empty
+      , greeting = Greeting "" "" 0
+      , greetingStyle = Animation.styleWith (Animation.spring wobbly) [ Animation.translate (percent 0) (percent (-300)) ]
+      , poll = Poll "" [] 0 0 True False ""
+      , pollStyle = Animation.styleWith (Animation.spring wobbly) [ Animation.translate (percent (-120)) (percent 0) ]
+      , effect = Effect "" "" ""
+      , effectStyle = Animation.styleWith (Animation.spring wobbly) [ Animation.opacity 0, Animation.scale 0 ]
       }
     , Cmd.none
     )
@@ -76,6 +128,7 @@ init _ =
 type Msg
     = Recv String
     | Animate Animation.Msg
+    | Tick Time.Posix
 
 
 update : Msg -> Model -> ( Model, Cmd Msg )
@@ -88,20 +141,43 @@ update msg model =
 
                 newMarqueeStyle =
                     Animation.update animMsg model.marqueeStyle
+
+                newGreetingStyle =
+                    Animation.update animMsg model.greetingStyle
+
+                newPollStyle =
+                    Animation.update animMsg model.pollStyle
+
+                newEffectStyle =
+                    Animation.update animMsg model.effectStyle
             in
             ( { model
                 | currentSongStyle = newCurrentSongStyle
                 , marqueeStyle = newMarqueeStyle
+                , greetingStyle = newGreetingStyle
+                , pollStyle = newPollStyle
+                , effectStyle = newEffectStyle
               }
             , Cmd.none
             )
 
+        Tick _ ->
+            let
+                poll =
+                    model.poll
+            in
+            if poll.closed || poll.secondsLeft <= 0 then
+                ( model, Cmd.none )
+
+            else
+                ( { model | poll = { poll | secondsLeft = poll.secondsLeft - 1 } }, Cmd.none )
+
         Recv message ->
             case D.decodeString websocketMessageDecoder message of
                 Ok ws ->
                     case ws.action of
                         "spotify_music_updated" ->
-                            case D.decodeString songInfoDecoder ws.payload of
+                            case D.decodeValue songInfoDecoder ws.payload of
                                 Ok song ->
                                     let
                                         newCurrentSongStyle =
@@ -123,27 +199,118 @@ update msg model =
                                     ( model, Cmd.none )
 
                         "tts_created" ->
-                            ( model, playUrl ws.payload )
+                            case D.decodeValue (D.field "audioUrl" D.string) ws.payload of
+                                Ok url ->
+                                    ( model, playUrl url )
+
+                                Err _ ->
+                                    ( model, Cmd.none )
 
                         "marquee_updated" ->
-                            let
-                                newMarqueeStyle =
-                                    Animation.interrupt
-                                        [ Animation.loop
-                                            [ Animation.to [ Animation.translate (percent 0) (percent 0) ]
-                                            , Animation.wait (Time.millisToPosix <| 60 * 1000)
-                                            , Animation.to [ Animation.translate (percent 0) (percent 100) ]
-                                            , Animation.wait (Time.millisToPosix <| 30 * 1000)
-                                            ]
-                                        ]
-                                        model.marqueeStyle
-                            in
-                            ( { model
-                                | marqueeMessage = ws.payload
-                                , marqueeStyle = newMarqueeStyle
-                              }
-                            , Cmd.none
-                            )
+                            case D.decodeValue (D.field "text" D.string) ws.payload of
+                                Ok marquee ->
+                                    let
+                                        newMarqueeStyle =
+                                            Animation.interrupt
+                                                [ Animation.loop
+                                                    [ Animation.to [ Animation.translate (percent 0) (percent 0) ]
+                                                    , Animation.wait (Time.millisToPosix <| 60 * 1000)
+                                                    , Animation.to [ Animation.translate (percent 0) (percent 100) ]
+                                                    , Animation.wait (Time.millisToPosix <| 30 * 1000)
+                                                    ]
+                                                ]
+                                                model.marqueeStyle
+                                    in
+                                    ( { model
+                                        | marqueeMessage = marquee
+                                        , marqueeStyle = newMarqueeStyle
+                                      }
+                                    , Cmd.none
+                                    )
+
+                                Err _ ->
+                                    ( model, Cmd.none )
+
+                        "counter_updated" ->
+                            case D.decodeValue counterDecoder ws.payload of
+                                Ok counter ->
+                                    ( { model | counters = Dict.insert counter.name counter.value model.counters }
+                                    , Cmd.none
+                                    )
+
+                                Err _ ->
+                                    ( model, Cmd.none )
+
+                        "viewer_greeted" ->
+                            case D.decodeValue greetingDecoder ws.payload of
+                                Ok greeting ->
+                                    let
+                                        newGreetingStyle =
+                                            Animation.interrupt
+                                                [ Animation.to [ Animation.translate (percent 0) (percent 0) ]
+                                                , Animation.wait (Time.millisToPosix <| 8 * 1000)
+                                                , Animation.to [ Animation.translate (percent 0) (percent (-300)) ]
+                                                ]
+                                                model.greetingStyle
+                                    in
+                                    ( { model
+                                        | greeting = greeting
+                                        , greetingStyle = newGreetingStyle
+                                      }
+                                    , Cmd.none
+                                    )
+
+                                Err _ ->
+                                    ( model, Cmd.none )
+
+                        "poll_updated" ->
+                            case D.decodeValue pollDecoder ws.payload of
+                                Ok poll ->
+                                    let
+                                        steps =
+                                            if poll.canceled then
+                                                [ Animation.to [ Animation.translate (percent (-120)) (percent 0) ] ]
+
+                                            else if poll.closed then
+                                                [ Animation.to [ Animation.translate (percent 0) (percent 0) ]
+                                                , Animation.wait (Time.millisToPosix <| 8 * 1000)
+                                                , Animation.to [ Animation.translate (percent (-120)) (percent 0) ]
+                                                ]
+
+                                            else
+                                                [ Animation.to [ Animation.translate (percent 0) (percent 0) ] ]
+                                    in
+                                    ( { model
+                                        | poll = poll
+                                        , pollStyle = Animation.interrupt steps model.pollStyle
+                                      }
+                                    , Cmd.none
+                                    )
+
+                                Err _ ->
+                                    ( model, Cmd.none )
+
+                        "overlay_effect" ->
+                            case D.decodeValue effectDecoder ws.payload of
+                                Ok effect ->
+                                    let
+                                        newEffectStyle =
+                                            Animation.interrupt
+                                                [ Animation.to [ Animation.opacity 1, Animation.scale 1 ]
+                                                , Animation.wait (Time.millisToPosix <| 6 * 1000)
+                                                , Animation.to [ Animation.opacity 0, Animation.scale 0 ]
+                                                ]
+                                                model.effectStyle
+                                    in
+                                    ( { model
+                                        | effect = effect
+                                        , effectStyle = newEffectStyle
+                                      }
+                                    , Cmd.none
+                                    )
+
+                                Err _ ->
+                                    ( model, Cmd.none )
 
                         _ ->
                             ( model, Cmd.none )
@@ -163,7 +330,11 @@ subscriptions model =
         , Animation.subscription Animate
             [ model.currentSongStyle
             , model.marqueeStyle
+            , model.greetingStyle
+            , model.pollStyle
+            , model.effectStyle
             ]
+        , Time.every 1000 Tick
         ]
 
 
@@ -181,6 +352,73 @@ songInfoView song =
     ]
 
 
+countersView : Dict String Int -> Html Msg
+countersView counters =
+    div [ class "counters" ]
+        (Dict.toList counters
+            |> List.map
+                (\( name, value ) ->
+                    div [ class "counter" ] [ text ("!" ++ name ++ ": " ++ String.fromInt value) ]
+                )
+        )
+
+
+greetingView : Greeting -> List (Html Msg)
+greetingView greeting =
+    case greeting.kind of
+        "first" ->
+            [ text ("🎉 Boas-vindas, " ++ greeting.user ++ "!") ]
+
+        "returning" ->
+            [ text ("👋 " ++ greeting.user ++ " voltou!") ]
+
+        "raid" ->
+            [ text ("🚨 RAID de " ++ greeting.user ++ " com " ++ String.fromInt greeting.viewers ++ " pessoas! 🚨") ]
+
+        _ ->
+            []
+
+
+pollView : Poll -> List (Html Msg)
+pollView poll =
+    let
+        total =
+            max 1 (List.sum (List.map .votes poll.options))
+
+        footer =
+            if poll.closed then
+                if poll.winner == "" then
+                    "Sem vencedor"
+
+                else
+                    "Resultado: " ++ poll.winner
+
+            else
+                String.fromInt poll.secondsLeft ++ "s · meta: " ++ String.fromInt poll.quorum
+    in
+    [ div [ class "poll-title" ] [ text poll.title ] ]
+        ++ List.map
+            (\option ->
+                div [ class "poll-option" ]
+                    [ div
+                        [ class "poll-bar"
+                        , style "width" (String.fromInt (option.votes * 100 // total) ++ "%")
+                        ]
+                        []
+                    , span [] [ text (option.name ++ ": " ++ String.fromInt option.votes) ]
+                    ]
+            )
+            poll.options
+        ++ [ div [ class "poll-footer" ] [ text footer ] ]
+
+
+effectView : Effect -> List (Html Msg)
+effectView effect =
+    [ div [ class "effect-user" ] [ text ("✨ " ++ effect.user ++ " ✨") ]
+    , div [ class "effect-text" ] [ text effect.text ]
+    ]
+
+
 view : Model -> Html Msg
 view model =
     div [ id "root" ]
@@ -194,6 +432,22 @@ view model =
                 ++ [ attribute "scrolldelay" "60" ]
             )
             [ text model.marqueeMessage ]
+        , countersView model.counters
+        , div
+            (Animation.render model.greetingStyle
+                ++ [ class "greeting" ]
+            )
+            (greetingView model.greeting)
+        , div
+            (Animation.render model.pollStyle
+                ++ [ class "poll" ]
+            )
+            (pollView model.poll)
+        , div
+            (Animation.render model.effectStyle
+                ++ [ class "effect", class ("effect-" ++ model.effect.name) ]
+            )
+            (effectView model.effect)
         ]
 
 
@@ -204,8 +458,50 @@ view model =
 websocketMessageDecoder : D.Decoder WebsocketMessage
 websocketMessageDecoder =
     D.map2 WebsocketMessage
-        (D.field "action" D.string)
-        (D.field "payload" D.string)
+        (D.field "type" D.string)
+        (D.field "payload" D.value)
+
+
+counterDecoder : D.Decoder Counter
+counterDecoder =
+    D.map2 Counter
+        (D.field "name" D.string)
+        (D.field "value" D.int)
+
+
+greetingDecoder : D.Decoder Greeting
+greetingDecoder =
+    D.map3 Greeting
+        (D.field "kind" D.string)
+        (D.field "user" D.string)
+        (D.oneOf [ D.field "viewers" D.int, D.succeed 0 ])
+
+
+effectDecoder : D.Decoder Effect
+effectDecoder =
+    D.map3 Effect
+        (D.field "effect" D.string)
+        (D.field "user" D.string)
+        (D.oneOf [ D.field "text" D.string, D.succeed "" ])
+
+
+pollDecoder : D.Decoder Poll
+pollDecoder =
+    D.map7 Poll
+        (D.field "title" D.string)
+        (D.field "options" (D.list pollOptionDecoder))
+        (D.oneOf [ D.field "quorum" D.int, D.succeed 0 ])
+        (D.field "secondsLeft" D.int)
+        (D.field "closed" D.bool)
+        (D.oneOf [ D.field "canceled" D.bool, D.succeed False ])
+        (D.oneOf [ D.field "winner" D.string, D.succeed "" ])
+
+
+pollOptionDecoder : D.Decoder PollOption
+pollOptionDecoder =
+    D.map2 PollOption
+        (D.field "name" D.string)
+        (D.field "votes" D.int)
 
 
 songInfoDecoder : D.Decoder SongInfo
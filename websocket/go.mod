@@ -3,8 +3,13 @@ module github.com/moniquelive/moniquelive-bot/websocket
 go 1.16
 
 require (
-	github.com/google/uuid v1.2.0
+	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/google/uuid v1.3.0 // indirect
 	github.com/gorilla/websocket v1.4.2
+	github.com/moniquelive/moniquelive-bot/shared v0.0.0-00010101000000-000000000000
 	github.com/sirupsen/logrus v1.8.1
-	github.com/streadway/amqp v1.0.0
+	github.com/streadway/amqp v1.0.0 // indirect
+	github.com/stretchr/testify v1.7.0
 )
+
+replace github.com/moniquelive/moniquelive-bot/shared => ../shared
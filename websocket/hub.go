@@ -0,0 +1,126 @@
+package main
+
+import "github.com/moniquelive/moniquelive-bot/shared/events"
+
+const clientSendBuffer = 16
+
+// replayedActions are the routing keys whose last payload is cached and
+// sent again to every client that connects (e.g. OBS reloading the scene).
+var replayedActions = []string{events.TopicSongUpdated, events.TopicMarqueeUpdated, events.TopicCounterUpdated}
+
+// client is a single websocket connection registered in the hub.
+type client struct {
+	send   chan []byte
+	replay chan [][]byte // the cached state, handed over once on register
+}
+
+func newClient() *client {
+	return &client{send: make(chan []byte, clientSendBuffer), replay: make(chan [][]byte, 1)}
+}
+
+// message is an already encoded websocket frame along with the event type
+// it carries. Replayed messages with the same key replace each other.
+type message struct {
+	action string
+	key    string
+	body   []byte
+}
+
+// hub keeps track of the connected websocket clients and fans every
+// AMQP delivery out to all of them.
+type hub struct {
+	clients    map[*client]bool
+	last       map[string][]byte // key x body
+	register   chan *client
+	unregister chan *client
+	broadcast  chan message
+	done       chan struct{} // closed once run returns
+}
+
+func newHub() *hub {
+	return &hub{
+		clients:    make(map[*client]bool),
+		last:       make(map[string][]byte),
+		register:   make(chan *client),
+		unregister: make(chan *client),
+		broadcast:  make(chan message, clientSendBuffer),
+		done:       make(chan struct{}),
+	}
+}
+
+// seed stores a payload as the last known state for key, without
+// broadcasting it. Must be called before run.
+func (h *hub) seed(key string, body []byte) {
+	h.last[key] = body
+}
+
+// join registers c, telling false if the hub is already shut down.
+func (h *hub) join(c *client) bool {
+	select {
+	case h.register <- c:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// leave unregisters c, if the hub is still running.
+func (h *hub) leave(c *client) {
+	select {
+	case h.unregister <- c:
+	case <-h.done:
+	}
+}
+
+func (h *hub) run() {
+	defer close(h.done)
+	for {
+		select {
+		case c := <-h.register:
+			// the replay goes apart from send, however many counters there are
+			replay := make([][]byte, 0, len(h.last))
+			for _, body := range h.last {
+				replay = append(replay, body)
+			}
+			c.replay <- replay
+			h.clients[c] = true
+			log.Infof("hub: client registered (%d online)", len(h.clients))
+		case c := <-h.unregister:
+			if _, ok := h.clients[c]; ok {
+				delete(h.clients, c)
+				close(c.send)
+			}
+			log.Infof("hub: client unregistered (%d online)", len(h.clients))
+		case msg, ok := <-h.broadcast:
+			if !ok {
+				for c := range h.clients {
+					delete(h.clients, c)
+					close(c.send)
+				}
+				return
+			}
+			if isReplayed(msg.action) {
+				h.last[msg.key] = msg.body
+			}
+			for c := range h.clients {
+				select {
+				case c.send <- msg.body:
+				default:
+					// cliente lento: derruba em vez de travar todo mundo
+					log.Warnln("hub: dropping slow client")
+					delete(h.clients, c)
+					close(c.send)
+				}
+			}
+		}
+	}
+}
+
+func isReplayed(action string) bool {
+	for _, a := range replayedActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
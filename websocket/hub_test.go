@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func startHub(t *testing.T) *hub {
+	h := newHub()
+	go h.run()
+	t.Cleanup(func() { close(h.broadcast) })
+	return h
+}
+
+// receive waits a bit for the next frame, ok is false if c.send was closed.
+func receive(t *testing.T, c *client) (body string, ok bool) {
+	select {
+	case b, ok := <-c.send:
+		return string(b), ok
+	case <-time.After(time.Second):
+		t.Fatal("nothing reached the client")
+		return "", false
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	h := startHub(t)
+	clients := []*client{newClient(), newClient(), newClient()}
+	for _, c := range clients {
+		h.register <- c
+	}
+	h.broadcast <- message{action: events.TopicTTSCreated, key: events.TopicTTSCreated, body: []byte("oi")}
+	h.broadcast <- message{action: events.TopicTTSCreated, key: events.TopicTTSCreated, body: []byte("tchau")}
+
+	for _, c := range clients {
+		body, ok := receive(t, c)
+		assert.True(t, ok)
+		assert.Equal(t, "oi", body)
+		body, _ = receive(t, c)
+		assert.Equal(t, "tchau", body)
+	}
+}
+
+func TestHubSlowClient(t *testing.T) {
+	h := startHub(t)
+	slow, fast := newClient(), newClient()
+	slow.send = make(chan []byte, 1)
+	h.register <- slow
+	h.register <- fast
+
+	for _, body := range []string{"1", "2", "3"} {
+		h.broadcast <- message{action: events.TopicTTSCreated, key: events.TopicTTSCreated, body: []byte(body)}
+	}
+	for _, expected := range []string{"1", "2", "3"} {
+		body, ok := receive(t, fast)
+		assert.True(t, ok)
+		assert.Equal(t, expected, body)
+	}
+
+	body, ok := receive(t, slow)
+	assert.True(t, ok)
+	assert.Equal(t, "1", body)
+	_, ok = receive(t, slow)
+	assert.False(t, ok, "slow client is dropped")
+}
+
+func TestHubUnregister(t *testing.T) {
+	h := startHub(t)
+	gone, stays := newClient(), newClient()
+	h.register <- gone
+	h.register <- stays
+
+	h.unregister <- gone
+	_, ok := receive(t, gone)
+	assert.False(t, ok)
+
+	h.broadcast <- message{action: events.TopicTTSCreated, key: events.TopicTTSCreated, body: []byte("oi")}
+	body, ok := receive(t, stays)
+	assert.True(t, ok)
+	assert.Equal(t, "oi", body)
+}
+
+func TestHubShutdown(t *testing.T) {
+	h := newHub()
+	go h.run()
+	c := newClient()
+	assert.True(t, h.join(c))
+	close(h.broadcast)
+	_, ok := receive(t, c)
+	assert.False(t, ok)
+
+	left := make(chan bool)
+	go func() {
+		h.leave(c)
+		left <- h.join(newClient())
+	}()
+	select {
+	case joined := <-left:
+		assert.False(t, joined)
+	case <-time.After(time.Second):
+		t.Fatal("leave/join blocked after shutdown")
+	}
+}
+
+func event(t *testing.T, ev events.Event) message {
+	body, err := events.Encode(producerName, ev)
+	assert.NoError(t, err)
+	env, err := events.Decode(body)
+	assert.NoError(t, err)
+	key, err := replayKey(env)
+	assert.NoError(t, err)
+	return message{action: env.Type, key: key, body: body}
+}
+
+func TestReplayKey(t *testing.T) {
+	var tt = []struct {
+		name     string
+		event    events.Event
+		expected string
+	}{
+		{"song", events.SongUpdated{Title: "Go"}, events.TopicSongUpdated},
+		{"marquee", events.MarqueeUpdated{Text: "oi"}, events.TopicMarqueeUpdated},
+		{"counter", events.CounterUpdated{Name: "mortes", Value: 3}, events.TopicCounterUpdated + ":mortes"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, event(t, tc.event).key)
+		})
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	h := newHub()
+	seedEvent(h, events.SongUpdated{Title: "Go"})
+	seedEvent(h, events.MarqueeUpdated{Text: "oi"})
+	for i := 0; i < 2*clientSendBuffer; i++ {
+		seedEvent(h, events.CounterUpdated{Name: fmt.Sprint("contador", i), Value: int64(i)})
+	}
+	go h.run()
+	defer close(h.broadcast)
+
+	first := newClient()
+	h.register <- first
+	assert.Len(t, <-first.replay, 2+2*clientSendBuffer, "the replay doesn't fit in send")
+	assert.Len(t, first.send, 0)
+
+	before := event(t, events.CounterUpdated{Name: "mortes", Value: 1})
+	after := event(t, events.CounterUpdated{Name: "mortes", Value: 2})
+	h.broadcast <- before
+	h.broadcast <- after
+	h.broadcast <- event(t, events.TTSCreated{})
+	h.unregister <- first
+
+	second := newClient()
+	h.register <- second
+	var replay []string
+	for _, body := range <-second.replay {
+		replay = append(replay, string(body))
+	}
+	assert.Len(t, replay, 3+2*clientSendBuffer, "counters replace each other, tts isn't replayed")
+	assert.Contains(t, replay, string(after.body))
+	assert.NotContains(t, replay, string(before.body))
+}
@@ -6,25 +6,29 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/go-redis/redis"
 	"github.com/gorilla/websocket"
+	"github.com/moniquelive/moniquelive-bot/shared/events"
+	"github.com/moniquelive/moniquelive-bot/shared/mq"
 	"github.com/sirupsen/logrus"
-	"github.com/streadway/amqp"
 )
 
 const (
-	queueName               = "ms.websocket"
-	spotifyTopicName        = "spotify_music_updated"
-	ttsCreatedTopicName     = "tts_created"
-	marqueeUpdatedTopicName = "marquee_updated"
+	queueName        = "ms.websocket"
+	producerName     = "websocket"
+	songInfoRedisKey = "twitch-bot:dbus:song-info"
+	marqueeRedisKey  = "twitch-bot:twitch:marquee:contents"
+	counterRedisKey  = "twitch-bot:twitch:counter:"
 )
 
 var (
-	amqpURL = os.Getenv("RABBITMQ_URL")
-	log     = logrus.WithField("package", "main")
+	amqpURL  = os.Getenv("RABBITMQ_URL")
+	redisURL = os.Getenv("REDIS_URL")
+	log      = logrus.WithField("package", "main")
 )
 
 var upgrader = websocket.Upgrader{
@@ -39,7 +43,7 @@ var upgrader = websocket.Upgrader{
 var obsNotifier embed.FS
 
 type wsHandler struct {
-	writerChan chan []byte
+	hub *hub
 }
 
 func init() {
@@ -57,17 +61,17 @@ func check(err error) {
 }
 
 func main() {
-	defer log.Debugln("AMQP consumer shutdown.")
-
 	var err error
-	wsChan := make(chan []byte)
+	wsHub := newHub()
+	seedFromRedis(wsHub)
+	go wsHub.run()
 
 	//
 	// Start websocket server
 	//
 	log.Println("Websocket Listening ...")
 	router := http.NewServeMux()
-	router.Handle("/ws", wsHandler{writerChan: wsChan})
+	router.Handle("/ws", wsHandler{hub: wsHub})
 	router.Handle("/obs/", http.FileServer(http.FS(obsNotifier)))
 
 	// start server in a goroutine
@@ -78,101 +82,100 @@ func main() {
 		}
 	}()
 
-	conn, err := amqp.Dial(amqpURL)
-	check(err)
-	defer conn.Close()
-	go func() { log.Debugf("closing: %s", <-conn.NotifyClose(make(chan *amqp.Error))) }()
-
-	log.Debugln("got Connection, getting Channel")
-	channel, err := conn.Channel()
-	check(err)
-	defer channel.Close()
-
-	log.Debugf("declaring Queue %q", queueName)
-	queue, err := channel.QueueDeclare(
-		queueName, // name of the queue
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // noWait
-		nil,       // arguments
-	)
-	check(err)
-
-	log.Debugf("binding Queue %q to amq.topic", queueName)
-	err = channel.QueueBind(queueName, spotifyTopicName, "amq.topic", false, nil)
-	err = channel.QueueBind(queueName, ttsCreatedTopicName, "amq.topic", false, nil)
-	err = channel.QueueBind(queueName, marqueeUpdatedTopicName, "amq.topic", false, nil)
-	check(err)
-
-	log.Debugln("Setting QoS")
-	err = channel.Qos(1, 0, true)
-	check(err)
-
-	log.Debugf("declared Queue (%q %d messages, %d consumers)", queue.Name, queue.Messages, queue.Consumers)
-
-	tag := uuid.NewString()
-	log.Debugf("starting Consume (tag:%q)", tag)
-	deliveries, err := channel.Consume(
-		queue.Name, // name
-		tag,        // consumerTag,
-		false,      // noAck
-		false,      // exclusive
-		false,      // noLocal
-		false,      // noWait
-		nil,        // arguments
-	)
+	client := mq.Dial(amqpURL)
+	err = client.Consume(mq.Topology{
+		Queue:  queueName,
+		Topics: []string{events.TopicSongUpdated, events.TopicTTSCreated, events.TopicMarqueeUpdated, events.TopicCounterUpdated, events.TopicViewerGreeted, events.TopicPollUpdated, events.TopicOverlayEffect},
+	}, func(d *mq.Delivery) error {
+		return handle(d, wsHub.broadcast)
+	})
 	check(err)
 
-	deliveriesDoneChan := make(chan struct{})
-	go handle(deliveries, wsChan, deliveriesDoneChan)
-
 	// wait for interrupt signal
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	for {
-		select {
-		case <-stopChan:
-			// will close the deliveries channel
-			err = channel.Cancel(tag, true)
-			check(err)
+	<-stopChan
 
-		// wait for go handle(...)
-		case <-deliveriesDoneChan:
-			return
-		}
+	// waits for the handler before closing the hub
+	_ = client.Close()
+	close(wsHub.broadcast)
+}
+
+func handle(delivery *mq.Delivery, ws chan<- message) error {
+	// o overlay recebe o envelope como veio, só conferimos a versão
+	env, err := events.Decode(delivery.Body)
+	if err != nil {
+		return mq.Permanent(err)
 	}
+	log.Infoln("DELIVERY:", string(delivery.Body))
+	key, err := replayKey(env)
+	if err != nil {
+		return mq.Permanent(err)
+	}
+	ws <- message{action: env.Type, key: key, body: delivery.Body}
+	return nil
 }
 
-func handle(deliveries <-chan amqp.Delivery, ws chan<- []byte, done chan<- struct{}) {
-	defer func() {
-		log.Println("AMQP Handler: Exiting from deliveries handler")
-		close(ws)
-		done <- struct{}{}
-	}()
-	log.Debugln("Listening...")
-	for delivery := range deliveries {
-		body := delivery.Body
-		if body == nil {
-			return
-		}
-		js := struct {
-			Action  string `json:"action"`
-			Payload string `json:"payload"`
-		}{
-			Action:  delivery.RoutingKey,
-			Payload: string(body),
+// replayKey tells which cached state an event replaces: there's only one
+// current song, but one value per counter.
+func replayKey(env *events.Envelope) (string, error) {
+	if env.Type != events.TopicCounterUpdated {
+		return env.Type, nil
+	}
+	var counter events.CounterUpdated
+	if err := env.Unmarshal(&counter); err != nil {
+		return "", err
+	}
+	return env.Type + ":" + counter.Name, nil
+}
+
+// seedFromRedis fills the hub replay cache with the state the other services
+// left in redis, so the first client doesn't have to wait for a new event.
+func seedFromRedis(h *hub) {
+	red := redis.NewClient(&redis.Options{Addr: redisURL})
+	defer red.Close()
+	if _, err := red.Ping().Result(); err != nil {
+		log.Println("seedFromRedis > Sem redis...")
+		return
+	}
+	if songInfo, err := red.Get(songInfoRedisKey).Bytes(); err == nil {
+		var song events.SongUpdated
+		if err := json.Unmarshal(songInfo, &song); err != nil {
+			log.Errorln("seedFromRedis > Unmarshal:", err)
+		} else {
+			seedEvent(h, song)
 		}
-		enc, err := json.Marshal(js)
-		if err != nil {
-			log.Errorln("websocket > handle > Marshal:", err)
+	}
+	if marquee, err := red.Get(marqueeRedisKey).Result(); err == nil {
+		seedEvent(h, events.MarqueeUpdated{Text: marquee})
+	}
+	keys, _ := red.Keys(counterRedisKey + "*").Result()
+	for _, key := range keys {
+		if value, err := red.Get(key).Int64(); err == nil {
+			seedEvent(h, events.CounterUpdated{Name: strings.TrimPrefix(key, counterRedisKey), Value: value})
 		}
-		_ = delivery.Ack(false)
-		log.Infoln("DELIVERY:", string(enc))
-		ws <- enc
 	}
 }
 
+func seedEvent(h *hub, ev events.Event) {
+	body, err := events.Encode(producerName, ev)
+	if err != nil {
+		log.Errorln("seedFromRedis > events.Encode:", err)
+		return
+	}
+	env, err := events.Decode(body)
+	if err != nil {
+		log.Errorln("seedFromRedis > events.Decode:", err)
+		return
+	}
+	key, err := replayKey(env)
+	if err != nil {
+		log.Errorln("seedFromRedis > replayKey:", err)
+		return
+	}
+	h.seed(key, body)
+}
+
 func (ws wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	const (
 		writeWait  = 10 * time.Second    // Time allowed to read the data from the client.
@@ -191,38 +194,53 @@ func (ws wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		log.Errorln("wsHandler: Upgrade error:", err)
 		return
 	}
+	defer conn.Close()
+
+	c := newClient()
+	if !ws.hub.join(c) {
+		return
+	}
 
 	go func() {
 		defer func() {
+			ws.hub.leave(c)
 			conn.Close()
-			ws.writerChan <- nil
 			log.Infoln("caindo fora do read pump!")
 		}()
 
 		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
 		conn.SetPongHandler(func(string) error { _ = conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
-				log.Errorln("error:", err)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
+					log.Errorln("error:", err)
+				}
+				log.Debugln("err != nil", err)
+				return
 			}
-			log.Debugln("err != nil", err)
-			return
 		}
 	}()
 
 	log.Println("Websocket Conectado!")
+	for _, body := range <-c.replay {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+			log.Errorln("ServeHTTP > replay:", err)
+			return
+		}
+	}
 	for {
 		select {
-		case body := <-ws.writerChan:
-			if body == nil {
-				log.Debugln("Body == nil")
+		case body, ok := <-c.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				log.Debugln("send channel closed")
+				_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
 			err := conn.WriteMessage(websocket.TextMessage, body)
 			if err != nil {
-				log.Errorln("ServeHTTP > writerChan:", err)
+				log.Errorln("ServeHTTP > send:", err)
 				return
 			}
 		case <-pingTicker.C: